@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/oliversabler/apa/token"
@@ -10,6 +11,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Span() token.Span
 }
 
 type Statement interface {
@@ -44,9 +46,21 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) == 0 {
+		return token.Span{}
+	}
+
+	first := p.Statements[0].Span()
+	last := p.Statements[len(p.Statements)-1].Span()
+
+	return token.Span{Start: first.Start, End: last.End}
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Close      token.Token
 }
 
 func (bs *BlockStatement) String() string {
@@ -63,6 +77,17 @@ func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BlockStatement) Span() token.Span {
+	end := bs.Token.Span.End
+	if bs.Close.Type != "" {
+		end = bs.Close.Span.End
+	} else if len(bs.Statements) > 0 {
+		end = bs.Statements[len(bs.Statements)-1].Span().End
+	}
+
+	return token.Span{Start: bs.Token.Span.Start, End: end}
+}
+
 func (bs *BlockStatement) statementNode() {}
 
 type LetStatement struct {
@@ -91,6 +116,14 @@ func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Span() token.Span {
+	if ls.Value != nil {
+		return spanBetween(ls.Token.Span, ls.Value)
+	}
+
+	return spanBetween(ls.Token.Span, ls.Name)
+}
+
 func (ls *LetStatement) statementNode() {}
 
 type Identifier struct {
@@ -106,6 +139,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i *Identifier) expressionNode() {}
 
 type ReturnStatement struct {
@@ -131,8 +168,168 @@ func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
 
+func (rs *ReturnStatement) Span() token.Span {
+	return spanBetween(rs.Token.Span, rs.ReturnValue)
+}
+
 func (rs *ReturnStatement) statementNode() {}
 
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("medan ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+
+func (ws *WhileStatement) Span() token.Span {
+	if ws.Body != nil {
+		return spanBetween(ws.Token.Span, ws.Body)
+	}
+
+	return ws.Token.Span
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+type ForStatement struct {
+	Token    token.Token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("för (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" i ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) Span() token.Span {
+	if fs.Body != nil {
+		return spanBetween(fs.Token.Span, fs.Body)
+	}
+
+	return fs.Token.Span
+}
+
+func (fs *ForStatement) statementNode() {}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.TokenLiteral() + ";"
+}
+
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+
+func (bs *BreakStatement) Span() token.Span {
+	return bs.Token.Span
+}
+
+func (bs *BreakStatement) statementNode() {}
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.TokenLiteral() + ";"
+}
+
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+
+func (cs *ContinueStatement) Span() token.Span {
+	return cs.Token.Span
+}
+
+func (cs *ContinueStatement) statementNode() {}
+
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *ThrowStatement) Span() token.Span {
+	return spanBetween(ts.Token.Span, ts.Value)
+}
+
+func (ts *ThrowStatement) statementNode() {}
+
+// ImportStatement binds the module loaded from Path to Name, which is the
+// file name of Path without its extension.
+type ImportStatement struct {
+	Token token.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) String() string {
+	return is.TokenLiteral() + " \"" + is.Path.Value + "\";"
+}
+
+func (is *ImportStatement) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *ImportStatement) Span() token.Span {
+	return spanBetween(is.Token.Span, is.Path)
+}
+
+func (is *ImportStatement) statementNode() {}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -150,11 +347,20 @@ func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
 
+func (es *ExpressionStatement) Span() token.Span {
+	if es.Expression != nil {
+		return es.Expression.Span()
+	}
+
+	return es.Token.Span
+}
+
 func (es *ExpressionStatement) statementNode() {}
 
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	Close    token.Token
 }
 
 func (al *ArrayLiteral) String() string {
@@ -177,11 +383,16 @@ func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
 
+func (al *ArrayLiteral) Span() token.Span {
+	return token.Span{Start: al.Token.Span.Start, End: al.Close.Span.End}
+}
+
 func (al *ArrayLiteral) expressionNode() {}
 
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Close token.Token
 }
 
 func (hl *HashLiteral) String() string {
@@ -203,11 +414,18 @@ func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
 
+func (hl *HashLiteral) Span() token.Span {
+	return token.Span{Start: hl.Token.Span.Start, End: hl.Close.Span.End}
+}
+
 func (hl *HashLiteral) expressionNode() {}
 
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	// Big holds the value of a literal too large for an int64, Value is then
+	// left as zero.
+	Big *big.Int
 }
 
 func (il *IntegerLiteral) String() string {
@@ -218,12 +436,36 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Span() token.Span {
+	return il.Token.Span
+}
+
 func (il *IntegerLiteral) expressionNode() {}
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Span() token.Span {
+	return fl.Token.Span
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string // The name it is bound to by låt, if any
 }
 
 func (fl *FunctionLiteral) String() string {
@@ -247,6 +489,14 @@ func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
 
+func (fl *FunctionLiteral) Span() token.Span {
+	if fl.Body != nil {
+		return spanBetween(fl.Token.Span, fl.Body)
+	}
+
+	return fl.Token.Span
+}
+
 func (fl *FunctionLiteral) expressionNode() {}
 
 type StringLiteral struct {
@@ -262,12 +512,17 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+func (sl *StringLiteral) Span() token.Span {
+	return sl.Token.Span
+}
+
 func (sl *StringLiteral) expressionNode() {}
 
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Close     token.Token
 }
 
 func (ce *CallExpression) String() string {
@@ -290,6 +545,12 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+func (ce *CallExpression) Span() token.Span {
+	start := spanOf(ce.Function, ce.Token)
+
+	return token.Span{Start: start.Start, End: ce.Close.Span.End}
+}
+
 func (ce *CallExpression) expressionNode() {}
 
 type IfExpression struct {
@@ -319,12 +580,63 @@ func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *IfExpression) Span() token.Span {
+	if ie.Alternative != nil {
+		return spanBetween(ie.Token.Span, ie.Alternative)
+	}
+
+	if ie.Consequence != nil {
+		return spanBetween(ie.Token.Span, ie.Consequence)
+	}
+
+	return ie.Token.Span
+}
+
 func (ie *IfExpression) expressionNode() {}
 
+type TryExpression struct {
+	Token     token.Token
+	Body      *BlockStatement
+	Parameter *Identifier
+	Handler   *BlockStatement
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("försök ")
+	out.WriteString(te.Body.String())
+	out.WriteString(" fånga (")
+	out.WriteString(te.Parameter.String())
+	out.WriteString(") ")
+	out.WriteString(te.Handler.String())
+
+	return out.String()
+}
+
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+
+func (te *TryExpression) Span() token.Span {
+	if te.Handler != nil {
+		return spanBetween(te.Token.Span, te.Handler)
+	}
+
+	if te.Body != nil {
+		return spanBetween(te.Token.Span, te.Body)
+	}
+
+	return te.Token.Span
+}
+
+func (te *TryExpression) expressionNode() {}
+
 type IndexExpression struct {
 	Token token.Token
 	Left  Expression
 	Index Expression
+	Close token.Token
 }
 
 func (ie *IndexExpression) String() string {
@@ -343,8 +655,66 @@ func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *IndexExpression) Span() token.Span {
+	start := spanOf(ie.Left, ie.Token)
+
+	return token.Span{Start: start.Start, End: ie.Close.Span.End}
+}
+
 func (ie *IndexExpression) expressionNode() {}
 
+// MemberExpression is Object.Member, a binding of a module.
+type MemberExpression struct {
+	Token  token.Token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
+func (me *MemberExpression) TokenLiteral() string {
+	return me.Token.Literal
+}
+
+func (me *MemberExpression) Span() token.Span {
+	start := spanOf(me.Object, me.Token)
+
+	return token.Span{Start: start.Start, End: me.Member.Span().End}
+}
+
+func (me *MemberExpression) expressionNode() {}
+
+type AssignExpression struct {
+	Token    token.Token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+func (ae *AssignExpression) Span() token.Span {
+	return spanBetween(spanOf(ae.Target, ae.Token), ae.Value)
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -366,6 +736,10 @@ func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
 
+func (pe *PrefixExpression) Span() token.Span {
+	return spanBetween(pe.Token.Span, pe.Right)
+}
+
 func (pe *PrefixExpression) expressionNode() {}
 
 type InfixExpression struct {
@@ -391,6 +765,10 @@ func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
 
+func (ie *InfixExpression) Span() token.Span {
+	return spanBetween(spanOf(ie.Left, ie.Token), ie.Right)
+}
+
 func (ie *InfixExpression) expressionNode() {}
 
 type Boolean struct {
@@ -406,4 +784,26 @@ func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
 
+func (b *Boolean) Span() token.Span {
+	return b.Token.Span
+}
+
 func (b *Boolean) expressionNode() {}
+
+// spanBetween joins the start of a span with the end of the node that closes
+// it. A missing end node, as left behind by a parse error, yields start as is.
+func spanBetween(start token.Span, end Node) token.Span {
+	if end == nil {
+		return start
+	}
+
+	return token.Span{Start: start.Start, End: end.Span().End}
+}
+
+func spanOf(node Node, fallback token.Token) token.Span {
+	if node == nil {
+		return fallback.Span
+	}
+
+	return node.Span()
+}
@@ -0,0 +1,1000 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/code"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/token"
+)
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+}
+
+// ErrModulesUnsupported is returned for programs that use importera, which
+// only the evaluator supports.
+var ErrModulesUnsupported = errors.New("modules are not supported by the vm engine")
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// position is where the node being compiled starts, recorded for the
+	// instructions emitted for it so that the VM can tell where errors are
+	// raised
+	position token.Position
+
+	// err is the first operand that did not fit in its instruction, which
+	// Compile returns once the node it was emitted for is compiled
+	err error
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+	positions           []object.SourcePosition
+	// tries counts the försök bodies being compiled, in which a call in
+	// tillbaka is not a tail call since its errors have to be caught
+	tries int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// loop tracks where fortsätt jumps to and which bryt jumps still have to be
+// pointed at the end of the loop once it is known. It also counts the försök
+// entered inside the loop, which bryt and fortsätt have to leave.
+type loop struct {
+	continueTarget int
+	breaks         []int
+	tries          int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string
+	Positions    []object.SourcePosition
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.position
+	c.position = node.Span().Start
+	defer func() { c.position = outer }()
+
+	if err := c.compile(node); err != nil {
+		return err
+	}
+
+	return c.err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.LetStatement:
+		// A function is bound before its body is compiled so that it can
+		// refer to itself, anything else sees the previous binding of the name
+		_, isFunction := node.Value.(*ast.FunctionLiteral)
+
+		var symbol Symbol
+		if isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if !isFunction {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		c.storeSymbol(symbol)
+
+	case *ast.ReturnStatement:
+		// A call in tillbaka replaces the frame of the function, like the
+		// evaluator runs tail calls without nesting them
+		call, isCall := node.ReturnValue.(*ast.CallExpression)
+		if isCall && c.scopeIndex > 0 && c.scopes[c.scopeIndex].tries == 0 {
+			c.position = call.Span().Start
+			if err := c.compileCallExpression(call, code.OpTailCall); err != nil {
+				return err
+			}
+		} else if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		current := c.currentLoop()
+		if current == nil {
+			return fmt.Errorf("bryt outside of loop")
+		}
+		c.leaveTries(current)
+		current.breaks = append(current.breaks, c.emit(code.OpLoopJump, 9999))
+
+	case *ast.ContinueStatement:
+		current := c.currentLoop()
+		if current == nil {
+			return fmt.Errorf("fortsätt outside of loop")
+		}
+		c.leaveTries(current)
+		c.emit(code.OpLoopJump, current.continueTarget)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+
+		// Sort the keys to emit the pairs in a deterministic order
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, k := range keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		return c.compileCallExpression(node, code.OpCall)
+
+	case *ast.ImportStatement, *ast.MemberExpression:
+		return fmt.Errorf("%s: %w: %s", node.Span().Start, ErrModulesUnsupported, node.String())
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+	}
+}
+
+/*
+   STATEMENT
+*/
+
+// compileWhileStatement and compileForStatement wrap the loop in OpLoop and
+// OpEndLoop, so that bryt and fortsätt can drop whatever the expressions they
+// are in have left on the stack.
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	c.emit(code.OpLoop)
+
+	// Each check of the condition is a step, as in the evaluator
+	conditionPos := c.emit(code.OpStep)
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	c.enterLoop(conditionPos)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, conditionPos)
+
+	endPos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, endPos)
+	c.leaveLoop(endPos)
+
+	c.emit(code.OpEndLoop)
+
+	// The loop itself evaluates to null, just like in the evaluator
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+// compileForStatement keeps an iterator on the stack for the duration of the
+// loop. OpIterNext pushes the next key and value, or jumps to the end once
+// the iterator is exhausted where the iterator is popped.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	hasKey := 0
+	if node.Key != nil {
+		hasKey = 1
+	}
+	c.emit(code.OpIter, hasKey)
+	c.emit(code.OpLoop)
+
+	nextPos := c.emit(code.OpIterNext, 9999)
+
+	// The loop variables shadow those of the same name outside the loop
+	value, previousValue := c.symbolTable.Shadow(node.Value.Value)
+	defer c.symbolTable.Restore(node.Value.Value, previousValue)
+	c.bindSymbol(value)
+
+	if node.Key != nil {
+		key, previousKey := c.symbolTable.Shadow(node.Key.Value)
+		defer c.symbolTable.Restore(node.Key.Value, previousKey)
+		c.bindSymbol(key)
+	} else {
+		c.emit(code.OpPop)
+	}
+
+	c.enterLoop(nextPos)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, nextPos)
+
+	endPos := len(c.currentInstructions())
+	c.changeOperand(nextPos, endPos)
+	c.leaveLoop(endPos)
+
+	c.emit(code.OpEndLoop)
+	c.emit(code.OpPop)
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+/*
+   EXPRESSION
+*/
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+// compileTryExpression makes the VM continue at the handler, with the error
+// value on the stack, when an error is raised before the OpEndTry.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	tryPos := c.emit(code.OpTry, 9999)
+
+	current := c.currentLoop()
+	if current != nil {
+		current.tries++
+	}
+	c.scopes[c.scopeIndex].tries++
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+
+	if current != nil {
+		current.tries--
+	}
+	c.scopes[c.scopeIndex].tries--
+
+	c.emit(code.OpEndTry)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+
+	// The parameter shadows a name of the same name for the handler only
+	parameter, previous := c.symbolTable.Shadow(node.Parameter.Value)
+	c.bindSymbol(parameter)
+
+	err := c.compileBlockValue(node.Handler)
+	c.symbolTable.Restore(node.Parameter.Value, previous)
+	if err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// compileBlockValue compiles a block so that it leaves the value of its last
+// statement on the stack, or null if that statement has no value.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// compileLogicalExpression short-circuits && and ||, leaving a boolean.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if node.Operator == "&&" {
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	} else {
+		c.emit(code.OpTrue)
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+// compileTruthiness leaves sant or falskt depending on whether the value of
+// node is truthy, a double negation does exactly that.
+func (c *Compiler) compileTruthiness(node ast.Expression) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+
+	return nil
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	operator := node.Operator[:len(node.Operator)-1]
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.resolve(target.Value)
+		if symbol.Scope == BuiltinScope {
+			return fmt.Errorf("cannot assign to builtin %s", target.Value)
+		}
+
+		// Reading the variable first fails the same way as in the evaluator
+		// when it has not been declared
+		c.loadSymbol(symbol)
+
+		if operator == "" {
+			c.emit(code.OpPop)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			op, ok := infixOperators[operator]
+			if !ok {
+				return fmt.Errorf("unknown operator %s", node.Operator)
+			}
+			c.emit(op)
+		}
+
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		// The operand is the opcode to combine the old and new value with,
+		// 0 for a plain assignment
+		op := 0
+		if operator != "" {
+			infix, ok := infixOperators[operator]
+			if !ok {
+				return fmt.Errorf("unknown operator %s", node.Operator)
+			}
+			op = int(infix)
+		}
+
+		c.emit(code.OpSetIndex, op)
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// compileCallExpression calls the function with op, OpCall or OpTailCall.
+func (c *Compiler) compileCallExpression(node *ast.CallExpression, op code.Opcode) error {
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+
+	for _, a := range node.Arguments {
+		if err := c.Compile(a); err != nil {
+			return err
+		}
+	}
+
+	c.emit(op, len(node.Arguments))
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	declared := map[string]bool{}
+	declaredNames(node.Body, declared)
+	c.symbolTable.Declare(declared)
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	freeNames := []string{}
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+		freeNames = append(freeNames, s.Name)
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		Name:          functionName(node),
+		Positions:     positions,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+/*
+   SYMBOLS
+*/
+
+// declaredNames adds the names node binds with låt to names, leaving out
+// those of the functions in it, which have scopes of their own.
+func declaredNames(node ast.Node, names map[string]bool) {
+	switch node := node.(type) {
+	case *ast.BlockStatement:
+		if node == nil {
+			return
+		}
+		for _, s := range node.Statements {
+			declaredNames(s, names)
+		}
+	case *ast.LetStatement:
+		names[node.Name.Value] = true
+		declaredNames(node.Value, names)
+	case *ast.ExpressionStatement:
+		declaredNames(node.Expression, names)
+	case *ast.ReturnStatement:
+		declaredNames(node.ReturnValue, names)
+	case *ast.ThrowStatement:
+		declaredNames(node.Value, names)
+	case *ast.WhileStatement:
+		declaredNames(node.Condition, names)
+		declaredNames(node.Body, names)
+	case *ast.ForStatement:
+		declaredNames(node.Iterable, names)
+		declaredNames(node.Body, names)
+	case *ast.IfExpression:
+		declaredNames(node.Condition, names)
+		declaredNames(node.Consequence, names)
+		declaredNames(node.Alternative, names)
+	case *ast.TryExpression:
+		declaredNames(node.Body, names)
+		declaredNames(node.Handler, names)
+	case *ast.PrefixExpression:
+		declaredNames(node.Right, names)
+	case *ast.InfixExpression:
+		declaredNames(node.Left, names)
+		declaredNames(node.Right, names)
+	case *ast.AssignExpression:
+		declaredNames(node.Target, names)
+		declaredNames(node.Value, names)
+	case *ast.IndexExpression:
+		declaredNames(node.Left, names)
+		declaredNames(node.Index, names)
+	case *ast.CallExpression:
+		declaredNames(node.Function, names)
+		for _, a := range node.Arguments {
+			declaredNames(a, names)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			declaredNames(el, names)
+		}
+	case *ast.HashLiteral:
+		for k, v := range node.Pairs {
+			declaredNames(k, names)
+			declaredNames(v, names)
+		}
+	}
+}
+
+// resolve looks up name, binding it as a global if no enclosing function
+// declares it either.
+// The program may still declare it before the code runs, otherwise the VM
+// reports it as not found.
+func (c *Compiler) resolve(name string) Symbol {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if !ok {
+		symbol = c.symbolTable.Global().Define(name)
+	}
+
+	return symbol
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// bindSymbol stores a value in a new variable for a block, which leaves the
+// cell of the previous variable in the slot to the closures capturing it.
+func (c *Compiler) bindSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpBindGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpBindLocal, s.Index)
+	}
+}
+
+// captureSymbol pushes the cell holding a variable for a closure to capture.
+func (c *Compiler) captureSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpCaptureGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	}
+}
+
+/*
+   INSTRUCTIONS
+*/
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands...)
+
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.markPosition(posNewInstruction)
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+// markPosition records that the instruction at offset is compiled from the
+// node being compiled. Entries for instructions that have been removed are
+// dropped, so that the offsets stay in order.
+func (c *Compiler) markPosition(offset int) {
+	scope := &c.scopes[c.scopeIndex]
+
+	for len(scope.positions) > 0 && scope.positions[len(scope.positions)-1].Offset >= offset {
+		scope.positions = scope.positions[:len(scope.positions)-1]
+	}
+
+	if n := len(scope.positions); n > 0 && scope.positions[n-1].Pos == c.position {
+		return
+	}
+
+	scope.positions = append(scope.positions, object.SourcePosition{Offset: offset, Pos: c.position})
+}
+
+// functionName names a function after the name it is bound to, or after where
+// it is written if it is anonymous, as the evaluator does.
+func functionName(fl *ast.FunctionLiteral) string {
+	if fl.Name != "" {
+		return fl.Name
+	}
+
+	return "funktion at " + fl.Span().Start.String()
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// checkOperands records an error for operands too large for the program to
+// run correctly, such as the slot of a function's 300th local.
+func (c *Compiler) checkOperands(op code.Opcode, operands ...int) {
+	if c.err != nil {
+		return
+	}
+
+	if err := code.CheckOperands(op, operands...); err != nil {
+		c.err = fmt.Errorf("%s: program too large for the vm engine: %w", c.position, err)
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, operand)
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+/*
+   SCOPES
+*/
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) enterLoop(continueTarget int) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{continueTarget: continueTarget})
+}
+
+func (c *Compiler) leaveLoop(endPos int) {
+	scope := &c.scopes[c.scopeIndex]
+	current := scope.loops[len(scope.loops)-1]
+
+	for _, pos := range current.breaks {
+		c.changeOperand(pos, endPos)
+	}
+
+	scope.loops = scope.loops[:len(scope.loops)-1]
+}
+
+// leaveTries leaves the försök entered inside a loop that bryt or fortsätt
+// jumps out of.
+func (c *Compiler) leaveTries(current *loop) {
+	for i := 0; i < current.tries; i++ {
+		c.emit(code.OpEndTry)
+	}
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+
+	return loops[len(loops)-1]
+}
@@ -0,0 +1,174 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope  SymbolScope = "GLOBAL"
+	LocalScope   SymbolScope = "LOCAL"
+	BuiltinScope SymbolScope = "BUILTIN"
+	FreeScope    SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	// Block is set for names bound anew each time a block runs, the
+	// variables of a loop and the parameter of a fånga, so that closures
+	// capture them even when they are globals
+	Block bool
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+
+	// declared holds the names the function of this table binds with låt,
+	// which functions nested in it resolve to this table even before the låt
+	// has been compiled
+	declared map[string]bool
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this table. Redefining a name that already lives in
+// this scope reuses its slot, like låt does in the evaluator, so that code
+// compiled earlier, such as a loop condition, sees the new value.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	return symbol
+}
+
+// Shadow binds name to a new slot until it is restored with the Symbol
+// returned, which is the zero Symbol if name was not bound in this table.
+// Loops use it for their variables, so that they leave a variable of the same
+// name outside the loop alone.
+func (s *SymbolTable) Shadow(name string) (Symbol, Symbol) {
+	previous := s.store[name]
+
+	scope := GlobalScope
+	if s.Outer != nil {
+		scope = LocalScope
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope, Block: true}
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	return symbol, previous
+}
+
+// Restore undoes Shadow, binding name to previous again.
+func (s *SymbolTable) Restore(name string, previous Symbol) {
+	if previous == (Symbol{}) {
+		delete(s.store, name)
+		return
+	}
+
+	s.store[name] = previous
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Declare records names bound with låt somewhere in the function of this
+// table, so that a function nested in it can call a function bound after it.
+func (s *SymbolTable) Declare(names map[string]bool) {
+	s.declared = names
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return s.resolve(name, false)
+}
+
+// resolve looks up name, nested being true when it is looked up for a table
+// enclosed by this one.
+func (s *SymbolTable) resolve(name string, nested bool) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && nested && s.declared[name] {
+		obj, ok = s.Define(name), true
+	}
+
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.resolve(name, true)
+		if !ok {
+			return obj, ok
+		}
+
+		if (obj.Scope == GlobalScope && !obj.Block) || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+
+	return obj, ok
+}
+
+// Global returns the outermost table, where names that are not yet declared
+// anywhere are bound so that they can be declared later in the program.
+func (s *SymbolTable) Global() *SymbolTable {
+	if s.Outer == nil {
+		return s
+	}
+
+	return s.Outer.Global()
+}
+
+// Names returns the names of the slots defined in this table, indexed by
+// slot, so the VM can name a variable that is read before it is set. The
+// slots of names no longer shadowed have no name.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefinitions)
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			names[symbol.Index] = symbol.Name
+		}
+	}
+
+	return names
+}
+
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
@@ -0,0 +1,153 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+}
+
+func TestRedefineReusesSlot(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.Define("b")
+
+	if a := global.Define("a"); a.Index != 0 {
+		t.Errorf("redefined a got new slot %d", a.Index)
+	}
+
+	if n := global.NumDefinitions(); n != 2 {
+		t.Errorf("wrong number of definitions. want=2, got=%d", n)
+	}
+}
+
+func TestShadow(t *testing.T) {
+	global := NewSymbolTable()
+	x := global.Define("x")
+
+	shadow, previous := global.Shadow("x")
+	if shadow.Index != 1 || previous != x {
+		t.Errorf("wrong shadow. got=%+v, previous=%+v", shadow, previous)
+	}
+
+	if resolved, _ := global.Resolve("x"); resolved != shadow {
+		t.Errorf("x not shadowed. got=%+v", resolved)
+	}
+
+	global.Restore("x", previous)
+	if resolved, _ := global.Resolve("x"); resolved != x {
+		t.Errorf("x not restored. got=%+v", resolved)
+	}
+
+	_, previous = global.Shadow("y")
+	global.Restore("y", previous)
+	if _, ok := global.Resolve("y"); ok {
+		t.Errorf("y still bound after restoring it")
+	}
+
+	if names := global.Names(); len(names) != 3 || names[1] != "" {
+		t.Errorf("wrong names. got=%q", names)
+	}
+}
+
+func TestResolveDeclared(t *testing.T) {
+	global := NewSymbolTable()
+	outer := NewEnclosedSymbolTable(global)
+	outer.Declare(map[string]bool{"g": true})
+	inner := NewEnclosedSymbolTable(outer)
+
+	if _, ok := outer.Resolve("g"); ok {
+		t.Errorf("g resolved in the table declaring it before it is defined")
+	}
+
+	g, ok := inner.Resolve("g")
+	if !ok || g.Scope != FreeScope {
+		t.Errorf("g not resolved as free in the nested table. got=%+v", g)
+	}
+
+	expected := Symbol{Name: "g", Scope: LocalScope, Index: 0}
+	if defined := outer.Define("g"); defined != expected {
+		t.Errorf("g not defined in the slot it was resolved to. want=%+v, got=%+v", expected, defined)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "b", Scope: FreeScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for _, sym := range expected {
+		result, ok := second.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("wrong free symbols. got=%+v", second.FreeSymbols)
+	}
+
+	if _, ok := second.Resolve("d"); ok {
+		t.Errorf("name d resolved, but was expected not to")
+	}
+}
+
+func TestNames(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "längd")
+	global.Define("a")
+	global.Define("b")
+
+	names := global.Names()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("wrong names. got=%v", names)
+	}
+
+	if global.Global() != global {
+		t.Errorf("global table is not its own Global")
+	}
+
+	local := NewEnclosedSymbolTable(NewEnclosedSymbolTable(global))
+	if local.Global() != global {
+		t.Errorf("Global did not return the outermost table")
+	}
+}
@@ -0,0 +1,365 @@
+// Package corpus holds programs shared by the tests of the evaluator and the
+// VM, so that both engines are held to the same results.
+package corpus
+
+type Case struct {
+	Input string
+	// Expected is the Inspect of the result, errors are written as they
+	// inspect, "ERROR: " followed by the message
+	Expected string
+}
+
+var Cases = []Case{
+	// Integers and floats
+	{"5", "5"},
+	{"-10", "-10"},
+	{"5 + 5 + 5 + 5 - 10", "10"},
+	{"2 * (5 + 10)", "30"},
+	{"50 / 2 * 2 + 10 - 5", "55"},
+	{"(5 + 10 * 2 + 15 / 3) * 2 + -10", "50"},
+	{"7 % 3", "1"},
+	{"-7 % 3", "-1"},
+	{"1.5 + 2.25", "3.75"},
+	{"1 + 0.5", "1.5"},
+	{"7.5 % 2", "1.5"},
+	{"-2.5", "-2.5"},
+	{"10 / 4.0", "2.5"},
+	{"2.0 * 3", "6.0"},
+
+	// Big integers
+	{"9223372036854775807 + 1", "9223372036854775808"},
+	{"-9223372036854775807 - 2", "-9223372036854775809"},
+	{"4611686018427387904 * 4", "18446744073709551616"},
+	{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+	{"123456789012345678901234567890", "123456789012345678901234567890"},
+	{"123456789012345678901234567890 / 10", "12345678901234567890123456789"},
+	{"123456789012345678901234567890 % 1000", "890"},
+	{"-123456789012345678901234567890 / 7", "-17636684144620811271604938270"},
+	{"(9223372036854775807 + 1) - 1", "9223372036854775807"},
+	{"(9223372036854775807 + 1) > 9223372036854775807", "true"},
+	{"18446744073709551616 == 4611686018427387904 * 4", "true"},
+	{"18446744073709551616 * 1.0", "1.8446744073709552e+19"},
+	{"låt f = funktion(n) { om (n < 2) { 1 } annars { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+	{"{18446744073709551616: 1}[4611686018427387904 * 4]", "1"},
+	{"18446744073709551616 / 0", "ERROR: division by zero"},
+	{"18446744073709551616 + sant", "ERROR: type mismatch: BIG_INTEGER + BOOLEAN"},
+
+	// Booleans and comparisons
+	{"sant", "true"},
+	{"!sant", "false"},
+	{"!!5", "true"},
+	{"!null_inte_definierad", "ERROR: identifier not found: null_inte_definierad"},
+	{"1 < 2", "true"},
+	{"1 > 2", "false"},
+	{"2 <= 2", "true"},
+	{"3 >= 4", "false"},
+	{"1 == 1.0", "true"},
+	{"1.5 < 2", "true"},
+	{"sant == sant", "true"},
+	{"sant != falskt", "true"},
+	{"(1 < 2) == sant", "true"},
+
+	// Logical operators
+	{"sant && falskt", "false"},
+	{"sant || falskt", "true"},
+	{"1 && 2", "true"},
+	{"falskt && x", "false"},
+	{"sant || x", "true"},
+	{"sant && x", "ERROR: identifier not found: x"},
+	{"1 < 2 och 2 < 3", "true"},
+	{"1 > 2 eller 2 > 3", "false"},
+
+	// Strings
+	{`"hej"`, "hej"},
+	{`"hej" + " " + "världen"`, "hej världen"},
+	{`"a\tb"`, "a\tb"},
+
+	// Conditionals
+	{"om (sant) { 10 }", "10"},
+	{"om (falskt) { 10 }", "null"},
+	{"om (1 < 2) { 10 } annars { 20 }", "10"},
+	{"om (1 > 2) { 10 } annars { 20 }", "20"},
+	{"om (om (falskt) { 10 }) { 10 } annars { 20 }", "20"},
+
+	// Bindings and assignment
+	{"låt a = 5; a;", "5"},
+	{"låt a = 5; låt b = a; låt c = a + b + 5; c;", "15"},
+	{"låt a = 1; a = 2; a;", "2"},
+	{"låt a = 1; a += 2", "3"},
+	{"låt a = 10; a -= 2; a *= 3; a /= 4; a %= 4; a", "2"},
+	{"låt a = 1; låt b = 1; a = b = 5; a + b", "10"},
+	{"a = 1", "ERROR: identifier not found: a"},
+	{"låt a = [1, 2, 3]; a[1] = 5; a", "[1, 5, 3]"},
+	{"låt a = [1, 2, 3]; a[2] += 5; a[2]", "8"},
+	{"låt a = [1]; a[1] = 2", "ERROR: index out of range: 1"},
+	{"låt a = [0]; a[0] = a; a", "[[...]]"},
+	{`låt h = {}; h["h"] = h; h`, "{h: {...}}"},
+	{`låt h = {"a": 1}; h["a"] += 1; h["b"] = 3; h["a"] + h["b"]`, "5"},
+	{`låt s = "a"; s[0] = "b"`, "ERROR: index assignment not supported: STRING"},
+
+	// Functions and closures
+	{"låt identitet = funktion(x) { x; }; identitet(5);", "5"},
+	{"låt dubbla = funktion(x) { x * 2; }; dubbla(5);", "10"},
+	{"låt add = funktion(x, y) { x + y; }; add(5 + 5, add(5, 5));", "20"},
+	{"funktion(x) { x; }(5)", "5"},
+	{"låt f = funktion() { tillbaka 1; 2 }; f()", "1"},
+	{`
+låt newAdder = funktion(x) {
+  funktion(y) { x + y };
+};
+
+låt addTwo = newAdder(2);
+addTwo(2);`, "4"},
+	{`
+låt räknare = funktion() {
+  låt n = 0;
+  funktion() { n += 1 };
+};
+
+låt r = räknare();
+r();
+r();
+r();`, "3"},
+	{`
+låt fib = funktion(x) {
+  om (x < 2) { tillbaka x; }
+  fib(x - 1) + fib(x - 2)
+};
+fib(15);`, "610"},
+	{`
+låt yttre = funktion() {
+  låt inre = funktion(n) { om (n == 0) { 0 } annars { n + inre(n - 1) } };
+  inre(4)
+};
+yttre();`, "10"},
+	{`
+låt a = 1;
+låt f = funktion() { a = a + 1 };
+f();
+f();
+a;`, "3"},
+	{"låt f = funktion() { g() }; låt g = funktion() { 2 }; f()", "2"},
+	{"låt yttre = funktion() { låt f = funktion() { g() }; låt g = funktion() { 2 }; f() }; yttre()", "2"},
+	{`låt jämn = funktion(n) {
+  låt är = funktion(n) { om (n == 0) { sant } annars { udda(n - 1) } };
+  låt udda = funktion(n) { om (n == 0) { falskt } annars { är(n - 1) } };
+  är(n)
+};
+jämn(10)`, "true"},
+	{"låt yttre = funktion() { låt f = funktion() { g }; om (sant) { låt g = 3 }; f() }; yttre()", "3"},
+	{"låt x = 1; låt f = funktion() { låt x = x + 1; x }; f()", "2"},
+	{"tillbaka 5; 10", "5"},
+
+	// Tail calls do not nest, other calls nest up to the same depth
+	{"låt summa = funktion(n, acc) { om (n == 0) { tillbaka acc }; tillbaka summa(n - 1, acc + n) }; summa(100000, 0)", "5000050000"},
+	{"låt f = funktion(n) { om (n == 0) { tillbaka längd([1, 2]) }; tillbaka f(n - 1) }; f(20000)", "2"},
+	{"låt f = funktion(n) { för (x i [1]) { tillbaka g(n) } }; låt g = funktion(n) { n + 1 }; [f(1), f(2)]", "[2, 3]"},
+	{"låt f = funktion(n) { om (n == 0) { tillbaka 0 }; försök { tillbaka f(n - 1) } fånga (e) { -1 } }; f(20000)", "-1"},
+	{"låt f = funktion() { tillbaka g(1) }; låt g = funktion(a, b) { a }; f()", "ERROR: wrong number of arguments: want=2, got=1"},
+	{"låt f = funktion(n) { om (n == 0) { 0 } annars { 1 + f(n - 1) } }; f(2000)", "2000"},
+	{"låt f = funktion(n) { 1 + f(n + 1) }; f(0)", "ERROR: maximalt rekursionsdjup överskridet"},
+	{"5(1)", "ERROR: not a function: INTEGER"},
+
+	// Loops
+	{"låt i = 0; medan (i < 10) { i += 1 }; i", "10"},
+	{"låt i = 0; medan (sant) { i += 1; om (i == 5) { bryt; } }; i", "5"},
+	{`
+låt i = 0;
+låt summa = 0;
+medan (i < 10) {
+  i += 1;
+  om (i % 2 == 0) { fortsätt; }
+  summa += i;
+}
+summa;`, "25"},
+	{"låt summa = 0; för (x i [1, 2, 3]) { summa += x }; summa", "6"},
+	{"låt summa = 0; för (i, x i [10, 20]) { summa += i }; summa", "1"},
+	{`låt s = ""; för (k i {"b": 2, "a": 1}) { s += k }; s`, "ab"},
+	{`låt s = 0; för (k, v i {"b": 2, "a": 1}) { s += v }; s`, "3"},
+	{`låt s = ""; för (c i "åäö") { s = c + s }; s`, "öäå"},
+	{"låt n = 0; för (x i [1, 2, 3, 4]) { om (x == 3) { bryt; } n += x }; n", "3"},
+	{"låt n = 0; för (x i [1, 2, 3, 4]) { om (x == 3) { fortsätt; } n += x }; n", "7"},
+	// bryt, fortsätt and tillbaka in an expression leave the rest of it
+	{"låt f = funktion() { låt r = [0]; för (x i [1,2,3]) { r[0] = 1 + om (x == 2) { fortsätt } annars { x } }; r }; f()", "[4]"},
+	{"låt n = 0; för (x i [1,2,3]) { n = n + om (x == 2) { bryt } annars { x } }; n", "1"},
+	{"låt n = 0; medan (n < 5) { n += 1; [n, om (n == 3) { bryt }] }; n", "3"},
+	{"låt f = funktion() { 1 + om (sant) { tillbaka 5 } }; f()", "5"},
+	{`
+låt hitta = funktion(xs, mål) {
+  för (i, x i xs) { om (x == mål) { tillbaka i; } }
+  -1
+};
+hitta([5, 6, 7], 7);`, "2"},
+	{`
+låt n = 0;
+för (a i [1, 2]) { för (b i [10, 20]) { n += a * b } }
+n;`, "90"},
+	{"medan (falskt) { 1 }", "null"},
+	{"för (x i 5) { x }", "ERROR: för not supported over INTEGER"},
+	{"låt x = 5\nför (x i [1, 2]) { x }\nx", "5"},
+	{"låt f = funktion() { låt x = 5\nför (x i [1, 2]) { x }\nx }; f()", "5"},
+	{"för (x i [1, 2]) { x }\nx", "ERROR: identifier not found: x"},
+
+	// Arrays, hashes and indexing
+	{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+	{"[1, 2, 3][0]", "1"},
+	{"[1, 2, 3][1 + 1]", "3"},
+	{"[1, 2, 3][3]", "null"},
+	{"[1, 2, 3][-1]", "null"},
+	{`{"ett": 1}["ett"]`, "1"},
+	{`{"ett": 1}["två"]`, "null"},
+	{`{1: "ett", sant: "sant"}[sant]`, "sant"},
+	{`{1.5: "x"}[1.5]`, "x"},
+	{"{}", "{}"},
+	{`{"a": 1}[funktion(x) { x }]`, "ERROR: unusable as hash key: FUNCTION"},
+	{"1[0]", "ERROR: index operator not supported: INTEGER"},
+
+	// Builtins
+	{`längd("")`, "0"},
+	{`längd("hej världen")`, "12"},
+	{"längd([1, 2, 3])", "3"},
+	{"första([1, 2, 3])", "1"},
+	{"sista([1, 2, 3])", "3"},
+	{"resterande([1, 2, 3])", "[2, 3]"},
+	{"första([])", "null"},
+	{"läggtill([], 1)", "[1]"},
+	{"avrunda(2.5)", "3"},
+	{"golv(2.7)", "2"},
+	{"tak(2.1)", "3"},
+	{"heltal(3.9)", "3"},
+	{"flyttal(3)", "3.0"},
+	{"längd(1)", "ERROR: argument to `längd` not supported, got=INTEGER"},
+	{`längd("ett", "två")`, "ERROR: wrong number of arguments. got=2, want=1"},
+
+	// Catching and throwing errors
+	{"försök { 1 } fånga (fel) { 2 }", "1"},
+	{"försök { 1 + sant } fånga (fel) { 2 }", "2"},
+	{`försök { 1 + sant } fånga (fel) { fel["typ"] + ": " + fel["meddelande"] }`, "typfel: type mismatch: INTEGER + BOOLEAN"},
+	{`försök { x } fånga (fel) { fel["typ"] }`, "namnfel"},
+	{`försök { [1][2] = 1 } fånga (fel) { fel["typ"] }`, "indexfel"},
+	{`försök { längd(1) } fånga (fel) { fel["typ"] }`, "argumentfel"},
+	{`försök { kasta "oj" } fånga (fel) { fel }`, `fel("oj", "fel")`},
+	{`försök { kasta fel("oj", "egetfel") } fånga (f) { f["typ"] }`, "egetfel"},
+	{`försök { kasta 5 } fånga (f) { f["meddelande"] }`, "5"},
+	{`försök { kasta "oj" } fånga (fel) { fel["finns inte"] }`, "null"},
+	{`låt fel = 1; försök { kasta "x" } fånga (fel) { 2 }; fel`, "1"},
+	{`försök { kasta "a" } fånga (fel) { 1 }; försök { kasta fel("b", "x") } fånga (f) { f["typ"] }`, "x"},
+	{`låt f = funktion() { låt e = 1; försök { kasta "x" } fånga (e) { e }; e }; f()`, "1"},
+	{`försök { kasta "x" } fånga (e) { låt y = 2 }; y`, "2"},
+	{`försök { kasta "x" } fånga (e) { 1 }; e`, "ERROR: identifier not found: e"},
+	{`låt n = 0; för (x i [1, 2]) { försök { kasta "x" } fånga (n) { 1 } }; n`, "0"},
+	// Closures capture the variables of the iteration or fånga they are made in
+	{"låt fs = []\nför (x i [1, 2]) { fs = läggtill(fs, funktion() { x }) }\nfs[0]() + fs[1]()", "3"},
+	{"låt f = funktion() { låt fs = []; för (i, x i [1, 2]) { fs = läggtill(fs, funktion() { i * 10 + x }) }; fs[0]() }; f()", "1"},
+	{"låt fs = []\nför (x i [1, 2]) { låt f = funktion() { x }; x += 10; fs = läggtill(fs, f) }\nfs[0]()", "11"},
+	{`låt fs = []; för (m i ["a", "b"]) { försök { kasta m } fånga (e) { fs = läggtill(fs, funktion() { e["meddelande"] }) } }; fs[0]()`, "a"},
+	{`
+låt f = funktion(n) { om (n == 0) { kasta "botten" } f(n - 1) };
+försök { f(10) } fånga (fel) { fel["meddelande"] }`, "botten"},
+	{`
+låt f = funktion() { kasta "inne" };
+låt g = funktion() { försök { tillbaka f(); } fånga (fel) { tillbaka 3; } };
+g()`, "3"},
+	{`
+låt g = funktion() { försök { tillbaka 1; } fånga (fel) { 2 } };
+g();
+försök { kasta "efter" } fånga (fel) { fel["meddelande"] }`, "efter"},
+	{`försök { försök { kasta "inre" } fånga (fel) { kasta fel } } fånga (yttre) { yttre["meddelande"] }`, "inre"},
+	{`
+låt n = 0;
+för (x i [1, 2, 3]) {
+  försök { om (x == 2) { kasta "två" } n += x } fånga (fel) { n += 10 }
+}
+n;`, "14"},
+	{`
+låt n = 0;
+medan (sant) { försök { n += 1; om (n == 3) { bryt; } } fånga (fel) { } }
+försök { kasta "ute" } fånga (fel) { n }`, "3"},
+	{`kasta "oj"`, "ERROR: oj"},
+	{`försök { kasta "oj" } fånga (fel) { kasta fel }`, "ERROR: oj"},
+	{`fel("oj")["typ"]`, "fel"},
+
+	// Errors
+	{"1 / 0", "ERROR: division by zero"},
+	{"1 % 0", "ERROR: division by zero"},
+	{"låt a = 1; a /= 0", "ERROR: division by zero"},
+	{`försök { 1 / 0 } fånga (fel) { fel["typ"] }`, "aritmetikfel"},
+	{"1.0 / 0", "+Inf"},
+	{"5 + sant;", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"5 + sant; 5;", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"-sant", "ERROR: unknown operator: -BOOLEAN"},
+	{"sant + falskt;", "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+	{`"hej" - "hej"`, "ERROR: unknown operator: STRING - STRING"},
+	{"om (10 > 1) { sant + falskt; }", "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+	{`
+om (10 > 1) {
+  om (10 > 1) {
+    tillbaka sant + falskt;
+  }
+
+  tillbaka 1;
+}`, "ERROR: unknown operator: BOOLEAN + BOOLEAN"},
+	{"foobar", "ERROR: identifier not found: foobar"},
+	{"låt f = funktion() { x }; f()", "ERROR: identifier not found: x"},
+	{"låt f = funktion(a, b) { a }; f(1)", "ERROR: wrong number of arguments: want=2, got=1"},
+	{"låt f = funktion(a) { a }; f(1, 2)", "ERROR: wrong number of arguments: want=1, got=2"},
+	{`låt f = funktion(a, b) { a }; försök { f(1) } fånga (fel) { fel["typ"] }`, "argumentfel"},
+}
+
+// TraceCase is a program both engines fail on, with the Trace of the error,
+// so that they agree on where errors are raised and the calls they
+// propagate out of.
+type TraceCase struct {
+	Input string
+	Trace string
+}
+
+var Traces = []TraceCase{
+	{"1 +\nsant", "ERROR: type mismatch: INTEGER + BOOLEAN\n\tat 1:1\n"},
+	{"låt f = funktion(x) { x[1] };\nf(1)", "ERROR: index operator not supported: INTEGER\n\tat 1:23\n\tin f called at 2:1\n"},
+	{
+		"låt f = funktion(n) {\n  om (n == 0) { kasta \"botten\" }\n  f(n - 1) + 1\n};\nf(2)",
+		"ERROR: botten\n\tat 2:17\n\tin f called at 3:3\n\t... repeated 1 more times\n\tin f called at 5:1\n",
+	},
+	{"låt f = funktion(a, b) { a };\n[f(1)]", "ERROR: wrong number of arguments: want=2, got=1\n\tat 2:2\n"},
+	{
+		"låt fångad = försök { kasta \"oj\" } fånga (fel) { fel };\nlåt g = funktion() { kasta fångad };\ng()",
+		"ERROR: oj\n\tat 2:22\n\tin g called at 3:1\n",
+	},
+	{
+		"låt f = funktion() { tillbaka g(1) };\nlåt g = funktion(x) { x + sant };\nf()",
+		"ERROR: type mismatch: INTEGER + BOOLEAN\n\tat 2:23\n\tin g called at 1:31\n",
+	},
+}
+
+// Budget is a program run with budgets or a cancelled context, which both
+// engines count the same way.
+type Budget struct {
+	Input          string
+	MaxSteps       int
+	MaxAllocations int
+	Cancelled      bool
+	Expected       string
+}
+
+var Budgets = []Budget{
+	{"låt i = 0; medan (i < 10) { i += 1 }; i", 11, 0, false, "10"},
+	{"låt i = 0; medan (i < 10) { i += 1 }; i", 10, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{"låt i = 0; medan (i < 10) { om (i == 5) { i += 1; fortsätt }; i += 1 }; i", 11, 0, false, "10"},
+	{"låt s = 0; för (x i [1, 2, 3]) { s += x }; s", 3, 0, false, "6"},
+	{"låt s = 0; för (x i [1, 2, 3]) { s += x }; s", 2, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{"låt f = funktion(n) { n }; f(1) + f(2)", 2, 0, false, "3"},
+	{"längd([1]) + längd([2])", 1, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{"låt f = funktion() { f() }; f()", 1000, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{"låt f = funktion() { tillbaka f() }; f()", 1000, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{"försök { medan (sant) { } } fånga (fel) { 1 }", 10, 0, false, "ERROR: evaluation stopped: step budget exceeded"},
+	{`låt s = ""; för (x i [1, 2, 3]) { s += "apa" }; s`, 0, 5, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{`låt s = ""; för (x i [1, 2, 3]) { s += "apa" }; s`, 0, 20, false, "apaapaapa"},
+	{`låt s = ""; medan (sant) { s += "apa" }`, 0, 1000, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{"låt a = []; för (x i [1, 2, 3]) { a = läggtill(a, [x, x, x]) }", 0, 5, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{"låt h = {}; för (x i [1, 2, 3]) { h = {1: h, 2: x} }", 0, 5, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{"låt a = [\"\"]; för (x i [1, 2, 3]) { a[0] += \"apa\" }", 0, 8, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{"låt x = 2; medan (sant) { x *= x }", 0, 1000, false, "ERROR: evaluation stopped: allocation budget exceeded"},
+	{"låt i = 0; medan (i < 100) { i += 1 }; i", 0, 0, true, "ERROR: evaluation stopped: context canceled"},
+	{"5 + 5", 0, 0, true, "10"},
+}
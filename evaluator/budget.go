@@ -0,0 +1,40 @@
+package evaluator
+
+import (
+	"github.com/oliversabler/apa/object"
+)
+
+// ErrStepBudget and ErrAllocationBudget are those of the object package, the
+// VM stops with the same errors.
+var (
+	ErrStepBudget       = object.ErrStepBudget
+	ErrAllocationBudget = object.ErrAllocationBudget
+)
+
+// step counts a function call or loop iteration and checks whether the
+// evaluation has to stop, because its context is done or a budget has run
+// out. The returned error stops the evaluation, the cause is kept in e.err.
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+
+	switch {
+	case e.err != nil:
+	case e.ctx != nil && e.ctx.Err() != nil:
+		e.err = e.ctx.Err()
+	case e.MaxSteps > 0 && e.steps > e.MaxSteps:
+		e.err = ErrStepBudget
+	case e.MaxAllocations > 0 && e.allocations > e.MaxAllocations:
+		e.err = ErrAllocationBudget
+	default:
+		return nil
+	}
+
+	return newError("evaluation stopped: %s", e.err)
+}
+
+// allocate counts obj towards the allocation budget and returns it.
+func (e *Evaluator) allocate(obj object.Object) object.Object {
+	e.allocations += object.Allocation(obj)
+
+	return obj
+}
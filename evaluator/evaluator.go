@@ -1,145 +1,303 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/oliversabler/apa/ast"
 	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// DefaultMaxDepth is how deep function calls may nest before evaluation stops
+// with an error, well before the Go stack of the host runs out.
+const DefaultMaxDepth = 10000
+
+// OverflowMode is what happens when integer arithmetic overflows.
+type OverflowMode int
+
+const (
+	// OverflowWrap wraps around like int64 arithmetic in Go
+	OverflowWrap OverflowMode = iota
+	// OverflowError stops the evaluation with an error
+	OverflowError
+	// OverflowPromote continues with an arbitrary-precision BigInt
+	OverflowPromote
+)
+
+var overflowModes = map[string]OverflowMode{
+	"wrap":    OverflowWrap,
+	"error":   OverflowError,
+	"promote": OverflowPromote,
+}
+
+// ParseOverflowMode returns the mode named wrap, error or promote.
+func ParseOverflowMode(name string) (OverflowMode, error) {
+	mode, ok := overflowModes[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown overflow mode %q, want wrap, error or promote", name)
+	}
+
+	return mode, nil
+}
+
+// Evaluator evaluates programs. Its settings apply to every call to Eval and
+// EvalContext, the budgets are counted from zero for each of them.
+type Evaluator struct {
+	// MaxDepth limits how deep function calls may nest, tail calls do not
+	// count towards it. Zero or less means no limit.
+	MaxDepth int
+
+	// MaxSteps limits the number of function calls and loop iterations.
+	// Zero or less means no limit.
+	MaxSteps int
+
+	// Overflow decides what happens when integer arithmetic overflows.
+	Overflow OverflowMode
+
+	// MaxAllocations limits the size of the values created, counted as one
+	// per value and one per element, pair or byte of arrays, hashes and
+	// strings and per machine word of big integers. Zero or less means no
+	// limit.
+	MaxAllocations int
+
+	// Builtins are the functions available to programs by name, unless the
+	// name is bound in the environment. Nil means the builtins of the object
+	// package.
+	Builtins map[string]*object.Builtin
+
+	// ReadFile reads the files imported with importera. Nil means
+	// os.ReadFile, NoImports and ImportsFrom limit what programs can read.
+	ReadFile func(name string) ([]byte, error)
+
+	modules   map[string]*object.Module // by absolute path
+	importing []string                  // the files being imported, outermost first
+
+	ctx         context.Context
+	depth       int
+	steps       int
+	allocations int
+	err         error
+}
+
+func New() *Evaluator {
+	return &Evaluator{MaxDepth: DefaultMaxDepth, Overflow: OverflowPromote}
+}
+
+// Eval evaluates node in env with the default settings.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	result, _ := e.EvalContext(context.Background(), node, env)
+	return result
+}
+
+// EvalContext evaluates node in env until it is done, ctx is done or a budget
+// runs out. In the latter two cases the error is ctx.Err(), ErrStepBudget or
+// ErrAllocationBudget and the result is an *object.Error saying the same.
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) (object.Object, error) {
+	e.ctx = ctx
+	e.depth = 0
+	e.steps = 0
+	e.allocations = 0
+	e.err = nil
+
+	result := e.eval(node, env)
+
+	return result, e.err
+}
+
+// eval evaluates node, recording the position of node on errors raised by it.
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	result := e.evalNode(node, env)
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Span().Start
+	}
+
+	return result
+}
+
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
-		if isError(val) {
+		val := e.eval(node.Value, env)
+		if isAbrupt(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok {
+			return e.evalTailCall(call, env)
+		}
+		val := e.eval(node.ReturnValue, env)
+		if isAbrupt(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
+
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isAbrupt(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return e.allocate(&object.Array{Elements: elements})
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: functionName(node), Parameters: params, Env: env, Body: body}
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
+		function := e.eval(node.Function, env)
+		if isAbrupt(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
+		args := e.evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isAbrupt(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.applyFunction(function, args, node.Span().Start)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
-		if isError(index) {
+		index := e.eval(node.Index, env)
+		if isAbrupt(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 
+	case *ast.MemberExpression:
+		return e.evalMemberExpression(node, env)
+
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := e.eval(node.Left, env)
+		if isAbrupt(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node.Operator, left, node.Right, env)
+		}
+		right := e.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return e.allocate(e.evalInfixExpression(node.Operator, left, right))
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
+		right := e.eval(node.Right, env)
+		if isAbrupt(right) {
 			return right
 		}
-		test := evalPrefixExpression(node.Operator, right)
+		test := e.evalPrefixExpression(node.Operator, right)
 		return test
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	}
 
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return e.unwrapReturnValue(result)
 		case *object.Error:
 			return result
+		case *object.Break, *object.Continue:
+			return loopControlError(result)
 		}
 	}
 
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		if result != nil {
-			resultType := result.Type()
-			if resultType == object.RETURN_VALUE_OBJ || resultType == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -149,12 +307,107 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		condition := e.eval(ws.Condition, env)
+		if isAbrupt(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if result, done := e.evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.eval(fs.Iterable, env)
+	if isAbrupt(iterable) {
+		return iterable
+	}
+
+	var keys, values []object.Object
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		values = iterable.Elements
+		for i := range values {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+		// A single binding iterates over the keys of a hash
+		if fs.Key == nil {
+			values = keys
+		}
+	case *object.String:
+		i := 0
+		for _, ch := range iterable.Value {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, &object.String{Value: string(ch)})
+			i++
+		}
+	default:
+		return newKindError(object.TYPE_ERROR_KIND, "för not supported over %s", iterable.Type())
+	}
+
+	for i := range values {
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		// Each iteration binds the loop variables in a scope of its own, so
+		// that they leave names outside the loop alone and closures capture
+		// the values of their iteration
+		iterationEnv := object.NewBlockEnvironment(env)
+		if fs.Key != nil {
+			iterationEnv.Bind(fs.Key.Value, keys[i])
+		}
+		iterationEnv.Bind(fs.Value.Value, values[i])
+
+		if result, done := e.evalLoopBody(fs.Body, iterationEnv); done {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// evalLoopBody runs one iteration of a loop. It reports whether the loop has
+// to stop, and with what result, because of bryt, tillbaka or an error.
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := e.eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	case object.BREAK_OBJ:
+		return NULL, true
+	default:
+		return nil, false
+	}
+}
+
+func (e *Evaluator) evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range expressions {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+	for _, exp := range expressions {
+		evaluated := e.eval(exp, env)
+		if isAbrupt(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -169,8 +422,10 @@ func evalIndexExpression(left, index object.Object) object.Object {
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorFieldExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "index operator not supported: %s", left.Type())
 	}
 }
 
@@ -193,7 +448,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", index.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -204,21 +459,32 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalErrorFieldExpression(errorValue, index object.Object) object.Object {
+	field, ok := errorValue.(*object.ErrorValue).Field(index.(*object.String).Value)
+	if !ok {
+		return NULL
+	}
+
+	return field
+}
+
+func (e *Evaluator) evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
 	case "-":
-		return evalMinusPrefixOperatorExpression(right)
+		return e.evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
+	case object.IsInteger(left) && object.IsInteger(right):
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
@@ -226,41 +492,84 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	default:
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func (e *Evaluator) evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		if (operator == "/" || operator == "%") && object.IsZero(right) {
+			return newKindError(object.ARITHMETIC_ERROR_KIND, "division by zero")
+		}
+
+		l, lok := left.(*object.Integer)
+		r, rok := right.(*object.Integer)
+		if e.Overflow == OverflowPromote || !lok || !rok {
+			return object.IntegerArithmetic(operator, left, right)
+		}
+
+		result, ok := object.Int64Arithmetic(operator, l.Value, r.Value)
+		if !ok && e.Overflow == OverflowError {
+			return newKindError(object.ARITHMETIC_ERROR_KIND,
+				"integer overflow: %d %s %d", l.Value, operator, r.Value)
+		}
+
+		return &object.Integer{Value: result}
+	case "<":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) != 0)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return &object.Float{Value: leftVal + rightVal}
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return &object.Float{Value: leftVal - rightVal}
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return &object.Float{Value: leftVal * rightVal}
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
@@ -269,21 +578,199 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
-	if isError(condition) {
+// evalLogicalExpression evaluates && and || with short-circuiting, the right
+// operand is only evaluated when the left one does not decide the result.
+func (e *Evaluator) evalLogicalExpression(
+	operator string,
+	left object.Object,
+	rightNode ast.Expression,
+	env *object.Environment,
+) object.Object {
+	if operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+
+	if operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+
+	right := e.eval(rightNode, env)
+	if isAbrupt(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		return e.evalIdentifierAssignment(node, target, env)
+	case *ast.IndexExpression:
+		return e.evalIndexAssignment(node, target, env)
+	default:
+		return newKindError(object.TYPE_ERROR_KIND, "cannot assign to %s", node.Target.String())
+	}
+}
+
+func (e *Evaluator) evalIdentifierAssignment(
+	node *ast.AssignExpression,
+	target *ast.Identifier,
+	env *object.Environment,
+) object.Object {
+	current, ok := env.Get(target.Value)
+	if !ok {
+		return newKindError(object.NAME_ERROR_KIND, "identifier not found: "+target.Value)
+	}
+
+	val := e.evalAssignedValue(node, current, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	env.Assign(target.Value, val)
+
+	return val
+}
+
+func (e *Evaluator) evalIndexAssignment(
+	node *ast.AssignExpression,
+	target *ast.IndexExpression,
+	env *object.Environment,
+) object.Object {
+	left := e.eval(target.Left, env)
+	if isAbrupt(left) {
+		return left
+	}
+
+	index := e.eval(target.Index, env)
+	if isAbrupt(index) {
+		return index
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newKindError(object.TYPE_ERROR_KIND, "index operator not supported: %s[%s]", left.Type(), index.Type())
+		}
+
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newKindError(object.INDEX_ERROR_KIND, "index out of range: %d", idx.Value)
+		}
+
+		val := e.evalAssignedValue(node, left.Elements[idx.Value], env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		left.Elements[idx.Value] = val
+
+		return val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newKindError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", index.Type())
+		}
+
+		current := object.Object(NULL)
+		if pair, ok := left.Pairs[key.HashKey()]; ok {
+			current = pair.Value
+		}
+
+		val := e.evalAssignedValue(node, current, env)
+		if isAbrupt(val) {
+			return val
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+
+		return val
+	default:
+		return newKindError(object.TYPE_ERROR_KIND, "index assignment not supported: %s", left.Type())
+	}
+}
+
+// evalAssignedValue evaluates the right hand side of an assignment. For a
+// compound assignment such as += it is combined with the current value.
+func (e *Evaluator) evalAssignedValue(node *ast.AssignExpression, current object.Object, env *object.Environment) object.Object {
+	val := e.eval(node.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	if node.Operator == "=" {
+		return val
+	}
+
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	return e.allocate(e.evalInfixExpression(operator, current, val))
+}
+
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
+	if isAbrupt(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
 }
 
+// evalTryExpression evaluates the body and, if it raises an error, the handler
+// with the error bound to the parameter. Errors stopping the evaluation, such
+// as a cancelled context, are not caught.
+func (e *Evaluator) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := e.eval(te.Body, env)
+
+	// A tail call has to be made here, inside the försök, for its errors to
+	// be caught
+	if returnValue, ok := result.(*object.ReturnValue); ok {
+		if _, ok := returnValue.Value.(*tailCall); ok {
+			result = e.unwrapReturnValue(returnValue)
+			if !isError(result) {
+				result = &object.ReturnValue{Value: result}
+			}
+		}
+	}
+
+	err, ok := result.(*object.Error)
+	if !ok || e.err != nil {
+		return result
+	}
+
+	// The parameter is bound for the handler only, it leaves a name or
+	// builtin of the same name outside alone
+	handlerEnv := object.NewBlockEnvironment(env)
+	handlerEnv.Bind(te.Parameter.Value, &object.ErrorValue{Error: err})
+
+	return e.eval(te.Handler, handlerEnv)
+}
+
+func (e *Evaluator) evalThrowStatement(ts *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.eval(ts.Value, env)
+	if isAbrupt(val) {
+		return val
+	}
+
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		// A caught error is thrown again as a new error raised here, the
+		// one the handler holds keeps where it was first raised
+		return &object.Error{Message: val.Error.Message, Kind: val.Error.Kind}
+	case *object.String:
+		return newError("%s", val.Value)
+	default:
+		return newError("%s", val.Inspect())
+	}
+}
+
 func evalBangOperatorExpression(right object.Object) object.Object {
 	switch right {
 	case TRUE:
@@ -297,31 +784,42 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+func (e *Evaluator) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		switch {
+		case right.Value != math.MinInt64:
+		case e.Overflow == OverflowPromote:
+			return object.Negate(right)
+		case e.Overflow == OverflowError:
+			return newKindError(object.ARITHMETIC_ERROR_KIND, "integer overflow: -(%d)", right.Value)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return object.Negate(right)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newKindError(object.TYPE_ERROR_KIND, "unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-
-	return &object.Integer{Value: -value}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
-		if isError(key) {
+		key := e.eval(keyNode, env)
+		if isAbrupt(key) {
 			return key
 		}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newKindError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
-		if isError(value) {
+		value := e.eval(valueNode, env)
+		if isAbrupt(value) {
 			return value
 		}
 
@@ -329,31 +827,118 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		pairs[hashed] = object.HashPair{Key: key, Value: value}
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return e.allocate(&object.Hash{Pairs: pairs})
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
+	builtins := e.Builtins
+	if builtins == nil {
+		builtins = defaultBuiltins
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newKindError(object.NAME_ERROR_KIND, "identifier not found: "+node.Value)
+}
+
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+// tailCall is returned, wrapped in a ReturnValue, by tillbaka f(...) instead
+// of making the call. Whoever unwraps the return value makes the call, so a
+// chain of tail calls runs in a loop rather than on the Go stack.
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	call token.Position
+}
+
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+func (e *Evaluator) evalTailCall(call *ast.CallExpression, env *object.Environment) object.Object {
+	function := e.eval(call.Function, env)
+	if isAbrupt(function) {
+		return function
+	}
+
+	args := e.evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isAbrupt(args[0]) {
+		return args[0]
+	}
+
+	return &object.ReturnValue{Value: &tailCall{fn: function, args: args, call: call.Span().Start}}
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, call token.Position) object.Object {
+	if _, ok := fn.(*object.Function); ok {
+		if e.MaxDepth > 0 && e.depth >= e.MaxDepth {
+			return newKindError(object.RECURSION_ERROR_KIND, "maximalt rekursionsdjup överskridet")
+		}
+
+		e.depth++
+		defer func() { e.depth-- }()
+	}
+
+	for {
+		if err := e.step(); err != nil {
+			return err
+		}
+
+		function, ok := fn.(*object.Function)
+		if !ok {
+			return e.allocate(applyNonFunction(fn, args))
+		}
+
+		if len(args) != len(function.Parameters) {
+			return newKindError(object.ARGUMENT_ERROR_KIND, "wrong number of arguments: want=%d, got=%d",
+				len(function.Parameters), len(args))
+		}
+
+		extendedEnv := extendFunctionEnv(function, args)
+		evaluated := e.eval(function.Body, extendedEnv)
+		switch evaluated := evaluated.(type) {
+		case *object.Break, *object.Continue:
+			return loopControlError(evaluated)
+		case *object.Error:
+			frame := object.StackFrame{Function: function.Name, Call: call}
+			evaluated.Stack = append(evaluated.Stack, frame)
+			return evaluated
+		}
+
+		returnValue, ok := evaluated.(*object.ReturnValue)
+		if !ok {
+			return evaluated
+		}
+
+		tc, ok := returnValue.Value.(*tailCall)
+		if !ok {
+			return returnValue.Value
+		}
+
+		fn, args, call = tc.fn, tc.args, tc.call
+	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyNonFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if result := fn.Fn(args...); result != nil {
+			return result
+		}
+		return NULL
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newKindError(object.TYPE_ERROR_KIND, "not a function: %s", fn.Type())
 	}
 }
 
@@ -367,12 +952,35 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Enviro
 	return env
 }
 
-func unwrapReturnValue(obj object.Object) object.Object {
-	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue.Value
+// unwrapReturnValue returns the value of a tillbaka, making the call first if
+// it was a tail call.
+func (e *Evaluator) unwrapReturnValue(obj object.Object) object.Object {
+	returnValue, ok := obj.(*object.ReturnValue)
+	if !ok {
+		return obj
+	}
+
+	if tc, ok := returnValue.Value.(*tailCall); ok {
+		return e.applyFunction(tc.fn, tc.args, tc.call)
+	}
+
+	return returnValue.Value
+}
+
+// functionName names a function after the name it is bound to, or after where
+// it is written if it is anonymous.
+func functionName(fl *ast.FunctionLiteral) string {
+	if fl.Name != "" {
+		return fl.Name
 	}
 
-	return obj
+	return "funktion at " + fl.Span().Start.String()
+}
+
+// loopControlError reports a bryt or fortsätt that escaped to a function or
+// program boundary without passing through a loop.
+func loopControlError(obj object.Object) *object.Error {
+	return newError("%s outside of loop", obj.Inspect())
 }
 
 func isError(obj object.Object) bool {
@@ -383,6 +991,39 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+// isAbrupt reports whether obj cuts short the expression it is the value of:
+// an error, or a tillbaka, bryt or fortsätt in a block inside the expression.
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return object.IsInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat widens a number to float64, callers must check isNumber first.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return object.BigToFloat(obj)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -407,3 +1048,7 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
+
+func newKindError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
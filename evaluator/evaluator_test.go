@@ -1,8 +1,16 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"runtime/debug"
 	"testing"
+	"time"
 
+	"github.com/oliversabler/apa/corpus"
 	"github.com/oliversabler/apa/lexer"
 	"github.com/oliversabler/apa/object"
 	"github.com/oliversabler/apa/parser"
@@ -270,6 +278,21 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == falskt", false},
 		{"(1 > 2) == sant", false},
 		{"(1 > 2) == falskt", true},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1.5 <= 1", false},
+		{"sant && sant", true},
+		{"sant && falskt", false},
+		{"falskt || sant", true},
+		{"falskt || falskt", false},
+		{"1 < 2 och 2 < 3", true},
+		{"1 > 2 eller 2 > 3", false},
+		{"5 && 0", true},
+		{"!sant || !falskt", true},
 	}
 
 	for _, tt := range tests {
@@ -298,6 +321,9 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 9 % 4 * 3", 5},
 	}
 
 	for _, tt := range tests {
@@ -306,6 +332,310 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"3 * 0.5 - 1", 0.5},
+		{"2e2 / 8", 25},
+		{"(1 + 2 + 3) / 4.0", 1.5},
+		{"7.5 % 2", 1.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"falskt && okänd", false},
+		{"sant || okänd", true},
+		{"falskt och okänd()", false},
+		{"sant eller okänd()", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval("sant && okänd")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Message != "identifier not found: okänd" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.0", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2.5 > 2.5", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumberBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"avrunda(2.5)", 3},
+		{"avrunda(-2.5)", -3},
+		{"avrunda(2.4)", 2},
+		{"avrunda(7)", 7},
+		{"avrunda(3.14159, 2)", 3.14},
+		{"golv(2.9)", 2},
+		{"golv(-2.1)", -3},
+		{"tak(2.1)", 3},
+		{"golv(7)", 7},
+		{"tak(-7)", -7},
+		{"avrunda(7, 2)", 7},
+		{"avrunda(1250, -2)", 1300},
+		{"avrunda(-1249, -2)", -1200},
+		{"avrunda(5, -3)", 0},
+		{"heltal(9.99)", 9},
+		{"heltal(-9.99)", -9},
+		{`heltal("42")`, 42},
+		{"flyttal(3)", 3.0},
+		{`flyttal("0.75")`, 0.75},
+		{"avrunda(sant)", "argument to `avrunda` not supported, got=BOOLEAN"},
+		{`avrunda(1.5, "två")`, "second argument to `avrunda` must be INTEGER, got=STRING"},
+		{`heltal("apa")`, `could not parse "apa" as integer`},
+		{"golv(1.0 / 0)", "result of `golv` out of range for INTEGER: +Inf"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"låt i = 0; medan (i < 10) { låt i = i + 1; }; i", 10},
+		{"medan (falskt) { 1 }", nil},
+		{"låt i = 0; medan (sant) { låt i = i + 1; om (i == 3) { bryt; } }; i", 3},
+		{
+			`låt i = 0; låt summa = 0;
+			medan (i < 10) {
+				låt i = i + 1;
+				om (i % 2 == 0) { fortsätt; }
+				låt summa = summa + i;
+			}
+			summa`,
+			25,
+		},
+		{
+			`låt f = funktion() {
+				låt i = 0;
+				medan (sant) {
+					låt i = i + 1;
+					om (i > 4) { tillbaka i * 10; }
+				}
+			};
+			f()`,
+			50,
+		},
+		{
+			`låt i = 0; låt n = 0;
+			medan (i < 3) {
+				låt i = i + 1;
+				låt j = 0;
+				medan (sant) {
+					låt j = j + 1;
+					låt n = n + 1;
+					om (j == 2) { bryt; }
+				}
+			}
+			n`,
+			6,
+		},
+		{"bryt;", "bryt outside of loop"},
+		{"om (sant) { fortsätt; }", "fortsätt outside of loop"},
+		{"medan (sant) { funktion() { bryt; }() }", "bryt outside of loop"},
+		{"medan (okänd) { 1 }", "identifier not found: okänd"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"låt s = 0; för (x i [1, 2, 3]) { låt s = s + x; }; s", 6},
+		{"låt s = 0; för (i, x i [10, 20, 30]) { låt s = s + i * x; }; s", 80},
+		{"för (x i []) { x }", nil},
+		{`låt s = ""; för (k i {"b": 2, "a": 1, "c": 3}) { låt s = s + k; }; s`, "abc"},
+		{`låt s = 0; för (k, v i {"a": 1, "b": 2}) { låt s = s + v; }; s`, 3},
+		{`låt s = ""; för (k, v i {2: "två", 1: "ett"}) { låt s = s + v; }; s`, "etttvå"},
+		{`låt s = ""; för (c i "åäö") { låt s = c + s; }; s`, "öäå"},
+		{`låt n = 0; för (i, c i "hej") { låt n = i; }; n`, 2},
+		{"låt s = 0; för (x i [1, 2, 3, 4]) { om (x == 3) { bryt; } låt s = s + x; }; s", 3},
+		{"låt s = 0; för (x i [1, 2, 3, 4]) { om (x == 3) { fortsätt; } låt s = s + x; }; s", 7},
+		{"låt f = funktion(l) { för (x i l) { om (x > 1) { tillbaka x; } } }; f([1, 5, 9])", 5},
+		{"för (x i 5) { x }", "för not supported over INTEGER"},
+		{"låt x = 5\nför (x i [1, 2]) { x }\nx", 5},
+		{"låt x = 5\nför (i, x i [1, 2]) { x = 10 }\nx", 5},
+		{"låt n = 0\nför (x i [1, 2]) { n += x }\nn", 3},
+		{"låt fs = []\nför (x i [1, 2]) { fs = läggtill(fs, funktion() { x }) }\nfs[0]() + fs[1]() * 10", 21},
+		{"för (x i [1, 2]) { x }\nx", "identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"låt x = 1; x = 2; x", 2},
+		{"låt x = 1; x = 5", 5},
+		{"låt x = 1; låt y = 2; x = y = 3; x + y", 6},
+		{"låt x = 10; x += 5; x", 15},
+		{"låt x = 10; x -= 5; x", 5},
+		{"låt x = 10; x *= 5; x", 50},
+		{"låt x = 10; x /= 5; x", 2},
+		{"låt x = 10; x %= 4; x", 2},
+		{"låt x = 1; x += 0.5; x", 1.5},
+		{`låt s = "hej"; s += " då"; s`, "hej då"},
+		{"låt i = 0; medan (i < 5) { i += 1 }; i", 5},
+		{
+			`låt räknare = funktion() {
+				låt n = 0;
+				funktion() { n += 1; n }
+			};
+			låt r = räknare();
+			r(); r(); r()`,
+			3,
+		},
+		{"låt x = 1; låt f = funktion() { låt x = 2; x = 3; x }; f() + x", 4},
+		{"låt l = [1, 2, 3]; l[0] = 10; l[0] + l[1]", 12},
+		{"låt l = [1, 2, 3]; l[2] += 5; l[2]", 8},
+		{"låt l = [1, 2]; låt m = l; m[0] = 7; l[0]", 7},
+		{`låt k = {"a": 1}; k["b"] = 2; k["a"] + k["b"]`, 3},
+		{`låt k = {"a": 1}; k["a"] *= 10; k["a"]`, 10},
+		{"y = 1", "identifier not found: y"},
+		{"y += 1", "identifier not found: y"},
+		{"låt l = [1]; l[1] = 2", "index out of range: 1"},
+		{`låt l = [1]; l["a"] = 2`, "index operator not supported: ARRAY[STRING]"},
+		{"låt k = {}; k[[]] = 1", "unusable as hash key: ARRAY"},
+		{`låt s = "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{`låt x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("object is not String or Error. got=%T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -392,6 +722,440 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+låt summa = funktion(n, acc) {
+  om (n == 0) { tillbaka acc; }
+  tillbaka summa(n - 1, acc + n);
+};
+summa(100000, 0);`, 5000050000},
+		{`
+låt jämn = funktion(n) { om (n == 0) { tillbaka 1; } tillbaka udda(n - 1); };
+låt udda = funktion(n) { om (n == 0) { tillbaka 0; } tillbaka jämn(n - 1); };
+jämn(100001);`, 0},
+		{`
+låt räkna = funktion(n) {
+  medan (sant) { om (n > 0) { tillbaka räkna(n - 1); } bryt; }
+  n
+};
+räkna(100000);`, 0},
+		{"låt f = funktion(x) { x * 2 }; tillbaka f(21);", 42},
+		{"låt f = funktion() { tillbaka längd([1, 2]); }; f()", 2},
+	}
+
+	// Without tail calls the recursion above needs far more Go stack than this
+	defer debug.SetMaxStack(debug.SetMaxStack(8 << 20))
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestRecursionDepthLimit(t *testing.T) {
+	input := `
+låt djup = funktion(n) { om (n == 0) { 0 } annars { 1 + djup(n - 1) } };
+djup(%d);`
+
+	evaluated := testEval(fmt.Sprintf(input, DefaultMaxDepth-1))
+	testIntegerObject(t, evaluated, DefaultMaxDepth-1)
+
+	// Unlimited recursion must stop with an error long before the Go stack
+	// of the host is exhausted
+	defer debug.SetMaxStack(debug.SetMaxStack(64 << 20))
+
+	evaluated = testEval("låt f = funktion(n) { 1 + f(n + 1) }; f(0);")
+	testErrorObject(t, evaluated, "maximalt rekursionsdjup överskridet")
+
+	l := lexer.New(fmt.Sprintf(input, 100))
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	e := New()
+	e.MaxDepth = 50
+	testErrorObject(t, e.Eval(program, object.NewEnvironment()), "maximalt rekursionsdjup överskridet")
+
+	e.MaxDepth = 101
+	testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 100)
+}
+
+func TestEvalContextBudgets(t *testing.T) {
+	tests := []struct {
+		input          string
+		maxSteps       int
+		maxAllocations int
+		expected       error
+	}{
+		{"medan (sant) { }", 1000, 0, ErrStepBudget},
+		{"låt f = funktion() { f() }; f()", 1000, 0, ErrStepBudget},
+		{"låt f = funktion() { tillbaka f() }; f()", 1000, 0, ErrStepBudget},
+		{`låt s = ""; medan (sant) { s += "apa" }`, 0, 1000, ErrAllocationBudget},
+		{"låt a = []; för (x i [1, 2, 3]) { a = läggtill(a, [x, x, x]) }", 0, 5, ErrAllocationBudget},
+		{"låt x = 2; medan (sant) { x *= x }", 0, 1000, ErrAllocationBudget},
+		{"låt x = 2; medan (sant) { x = x * x }", 0, 1000, ErrAllocationBudget},
+		{"låt i = 0; medan (i < 10) { i += 1 }; i", 11, 100, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		e := New()
+		e.MaxSteps = tt.maxSteps
+		e.MaxAllocations = tt.maxAllocations
+
+		evaluated, err := e.EvalContext(context.Background(), program, object.NewEnvironment())
+		if err != tt.expected {
+			t.Errorf("wrong error for %q. want=%v, got=%v", tt.input, tt.expected, err)
+			continue
+		}
+
+		if err != nil {
+			testErrorObject(t, evaluated, "evaluation stopped: "+err.Error())
+		}
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	l := lexer.New("låt f = funktion(n) { n + 1 }; låt i = 0; medan (sant) { i = f(i) }")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	evaluated, err := New().EvalContext(ctx, program, object.NewEnvironment())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrong error. want=%v, got=%v", context.DeadlineExceeded, err)
+	}
+
+	testErrorObject(t, evaluated, "evaluation stopped: context deadline exceeded")
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `låt inre = funktion(x) {
+  x + y
+};
+låt yttre = funktion() {
+  inre(1)
+};
+låt svans = funktion() { tillbaka yttre(); };
+[1, funktion() { svans() }()]`
+
+	evaluated := testEval(input)
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if err.Pos.String() != "2:7" {
+		t.Errorf("wrong error position. want=%q, got=%q", "2:7", err.Pos.String())
+	}
+
+	expected := []struct {
+		function string
+		call     string
+	}{
+		{"inre", "5:3"},
+		{"yttre", "7:35"},
+		{"funktion at 8:5", "8:5"},
+	}
+
+	if len(err.Stack) != len(expected) {
+		t.Fatalf("wrong stack length. want=%d, got=%d (%+v)", len(expected), len(err.Stack), err.Stack)
+	}
+
+	for i, frame := range expected {
+		if err.Stack[i].Function != frame.function {
+			t.Errorf("frame %d has wrong function. want=%q, got=%q", i, frame.function, err.Stack[i].Function)
+		}
+		if err.Stack[i].Call.String() != frame.call {
+			t.Errorf("frame %d has wrong call. want=%q, got=%q", i, frame.call, err.Stack[i].Call.String())
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`försök { 1 } fånga (fel) { 2 }`, 1},
+		{`försök { 1 + sant } fånga (fel) { 2 }`, 2},
+		{`försök { 1 + sant } fånga (fel) { fel["meddelande"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`försök { 1 + sant } fånga (fel) { fel["typ"] }`, "typfel"},
+		{`försök { x } fånga (fel) { fel["typ"] }`, "namnfel"},
+		{`försök { {}[[]] } fånga (fel) { fel["typ"] }`, "typfel"},
+		{`försök { längd(1) } fånga (fel) { fel["typ"] }`, "argumentfel"},
+		{`försök { kasta "oj"; 1 } fånga (fel) { fel["meddelande"] }`, "oj"},
+		{`försök { kasta "oj" } fånga (fel) { fel["typ"] }`, "fel"},
+		{`försök { kasta fel("oj", "egetfel") } fånga (f) { f["typ"] + ": " + f["meddelande"] }`, "egetfel: oj"},
+		{`
+låt f = funktion() { kasta "inne" };
+låt g = funktion() { tillbaka f(); };
+försök { g() } fånga (fel) { fel["meddelande"] }`, "inne"},
+		{`
+låt g = funktion() { försök { tillbaka 1 + sant; } fånga (fel) { tillbaka 2; } };
+g()`, 2},
+		{`
+låt f = funktion() { kasta "inne" };
+låt g = funktion() { försök { tillbaka f(); } fånga (fel) { tillbaka 3; } };
+g()`, 3},
+		{`
+låt f = funktion(n) { om (n == 0) { kasta "botten" } f(n - 1) };
+försök { f(10) } fånga (fel) { fel["meddelande"] }`, "botten"},
+		{`försök { försök { kasta "inre" } fånga (fel) { kasta fel } } fånga (yttre) { yttre["meddelande"] }`, "inre"},
+		{`låt n = 0; för (x i [1, 2, 3]) { försök { om (x == 2) { kasta "två" } n += x } fånga (fel) { n += 10 } }; n`, 14},
+		{`låt f = funktion() { f() }; försök { f() } fånga (fel) { fel["typ"] }`, "rekursionsfel"},
+		{`försök { kasta "oj" } fånga (fel) { fel["finns inte"] }`, nil},
+		{`kasta "oj"`, errorMessage("oj")},
+		{`försök { kasta "oj" } fånga (fel) { kasta fel }`, errorMessage("oj")},
+		{`kasta 5`, errorMessage("5")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringObject(t, evaluated, expected)
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestRethrowKeepsCaughtError(t *testing.T) {
+	env := object.NewEnvironment()
+
+	first := `låt f = funktion() { kasta "oj" };
+låt fångad = försök { f() } fånga (fel) { fel };`
+	Eval(parser.New(lexer.New(first)).ParseProgram(), env)
+
+	caught, ok := env.Get("fångad")
+	if !ok {
+		t.Fatalf("fångad not bound")
+	}
+	original := caught.(*object.ErrorValue).Error
+
+	second := `låt g = funktion() { kasta fångad };
+g()`
+	evaluated := Eval(parser.New(lexer.New(second)).ParseProgram(), env)
+
+	rethrown, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if rethrown == original {
+		t.Fatalf("kasta threw the caught error itself")
+	}
+
+	if rethrown.Message != "oj" || rethrown.Pos.String() != "1:22" || len(rethrown.Stack) != 1 {
+		t.Errorf("wrong rethrown error. got=%q at %s, stack %+v", rethrown.Message, rethrown.Pos, rethrown.Stack)
+	}
+
+	if original.Pos.String() != "1:22" || len(original.Stack) != 1 || original.Stack[0].Function != "f" {
+		t.Errorf("caught error changed by rethrowing it. got=%s, stack %+v", original.Pos, original.Stack)
+	}
+}
+
+func TestTryDoesNotCatchBudgetErrors(t *testing.T) {
+	l := lexer.New(`försök { medan (sant) { } } fånga (fel) { 1 }`)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	e := New()
+	e.MaxSteps = 100
+
+	_, err := e.EvalContext(context.Background(), program, object.NewEnvironment())
+	if err != ErrStepBudget {
+		t.Errorf("wrong error. want=%v, got=%v", ErrStepBudget, err)
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"9223372036854775807 + 1", errorMessage("integer overflow: 9223372036854775807 + 1")},
+		{"-9223372036854775807 - 2", errorMessage("integer overflow: -9223372036854775807 - 2")},
+		{"4611686018427387904 * 2", errorMessage("integer overflow: 4611686018427387904 * 2")},
+		{"(-9223372036854775807 - 1) / -1", errorMessage("integer overflow: -9223372036854775808 / -1")},
+		{"-(-9223372036854775807 - 1)", errorMessage("integer overflow: -(-9223372036854775808)")},
+		{"låt a = 9223372036854775807; a += 1", errorMessage("integer overflow: 9223372036854775807 + 1")},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-4611686018427387904 * 2", -9223372036854775808},
+		{"(-9223372036854775807 - 1) % -1", 0},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		e := New()
+		e.Overflow = OverflowError
+		evaluated := e.Eval(program, object.NewEnvironment())
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorMessage:
+			testErrorObject(t, evaluated, string(expected))
+		}
+	}
+
+	e := New()
+	e.Overflow = OverflowWrap
+	evaluated := e.Eval(parser.New(lexer.New("9223372036854775807 + 1")).ParseProgram(), object.NewEnvironment())
+	testIntegerObject(t, evaluated, -9223372036854775808)
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"heltal(\"100000000000000000000\")", "100000000000000000000"},
+		{"golv(1e20)", "100000000000000000000"},
+		{"golv(9007199254740993)", "9007199254740993"},
+		{"avrunda(9223372036854775807)", "9223372036854775807"},
+		{"tak(100000000000000000001)", "100000000000000000001"},
+		{"avrunda(100000000000000000051, -2)", "100000000000000000100"},
+		{"flyttal(100000000000000000000)", "1e+20"},
+		{"låt a = 9223372036854775807; a += 1; a", "9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if evaluated := testEval("9223372036854775807 + 1"); evaluated.Type() != object.BIG_INTEGER_OBJ {
+		t.Errorf("overflow did not promote to BIG_INTEGER. got=%s", evaluated.Type())
+	}
+
+	testIntegerObject(t, testEval("(9223372036854775807 + 1) - 1"), 9223372036854775807)
+}
+
+func TestImports(t *testing.T) {
+	files := map[string]string{
+		"matte.apa":   `låt pi = 3; låt kvadrat = funktion(x) { x * x };`,
+		"lib/a.apa":   `importera "b.apa"; låt dubbel = b.tal * 2;`,
+		"lib/b.apa":   `låt tal = 21;`,
+		"cykel/x.apa": `importera "y.apa"; låt x = 1;`,
+		"cykel/y.apa": `importera "x.apa"; låt y = 1;`,
+		"åter.apa":    `importera "main.apa"; låt t = 1;`,
+		"trasig.apa":  `låt = 1;`,
+		"fel.apa": `låt a = 1;
+a + sant;`,
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`importera "matte.apa"; matte.kvadrat(matte.pi)`, 9},
+		{`importera "matte.apa"; matte`, `modul "matte.apa"`},
+		{`importera "lib/a.apa"; a.dubbel`, 42},
+		{`importera "lib/a.apa"; a.b`, "module a has no binding b"},
+		{`importera "lib/a.apa"; a.b.tal`, "module a has no binding b"},
+		{`importera "matte.apa"; matte.x`, "module matte has no binding x"},
+		{`importera "matte.apa"; matte.e`, "module matte has no binding e"},
+		{`låt x = 1; x.y`, "member access not supported: INTEGER.y"},
+		{`importera "saknas.apa"`, `cannot import "saknas.apa": file does not exist`},
+		{`importera "trasig.apa"`, `cannot import "trasig.apa": trasig.apa:1:5: expected next token to be IDENT, got==`},
+		{`importera "cykel/x.apa"`, "import cycle: x.apa -> y.apa -> x.apa"},
+		{`importera "åter.apa"`, "import cycle: main.apa -> åter.apa -> main.apa"},
+		{`importera "fel.apa"`, "type mismatch: INTEGER + BOOLEAN"},
+		{`försök { importera "saknas.apa" } fånga (fel) { fel["typ"] }`, "importfel"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFiles(New(), files, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if err, ok := evaluated.(*object.Error); ok {
+				testErrorObject(t, err, expected)
+			} else if evaluated.Inspect() != expected {
+				t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+// TestModuleExportsAreLive checks that an export reassigned inside the module
+// reads as its new value through the module.
+func TestModuleExportsAreLive(t *testing.T) {
+	files := map[string]string{
+		"räknare.apa": `låt värde = 0; låt öka = funktion() { värde += 1 };`,
+	}
+
+	evaluated := testEvalFiles(New(), files, `importera "räknare.apa";
+låt före = räknare.värde;
+räknare.öka();
+[före, räknare.värde]`)
+
+	if evaluated.Inspect() != "[0, 1]" {
+		t.Errorf("wrong result. want=%q, got=%q", "[0, 1]", evaluated.Inspect())
+	}
+}
+
+func TestImportsAreCached(t *testing.T) {
+	files := map[string]string{
+		"räknare.apa": `låt värde = 0; låt öka = funktion() { värde += 1 };`,
+	}
+
+	e := New()
+	testEvalFiles(e, files, `importera "räknare.apa"; räknare.öka();`)
+
+	evaluated := testEvalFiles(e, files, `importera "./räknare.apa"; räknare.öka(); räknare.värde`)
+	testIntegerObject(t, evaluated, 2)
+}
+
+func TestImportErrorStackTrace(t *testing.T) {
+	files := map[string]string{
+		"fel.apa": "låt a = 1;\na + sant;",
+	}
+
+	evaluated := testEvalFiles(New(), files, `låt x = 1;
+importera "fel.apa";`)
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if err.Pos.String() != "fel.apa:2:1" {
+		t.Errorf("wrong error position. want=%q, got=%q", "fel.apa:2:1", err.Pos.String())
+	}
+
+	if len(err.Stack) != 1 || err.Stack[0].Call.String() != "main.apa:2:1" {
+		t.Fatalf("wrong stack. got=%+v", err.Stack)
+	}
+
+	if function := err.Stack[0].Function; function != `importera "fel.apa"` {
+		t.Errorf("wrong function in frame. want=%q, got=%q", `importera "fel.apa"`, function)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "funktion(x) { x + 2; };"
 
@@ -505,6 +1269,58 @@ func TestStringConcatination(t *testing.T) {
 	}
 }
 
+func TestCorpus(t *testing.T) {
+	for _, tt := range corpus.Cases {
+		evaluated := testEval(tt.Input)
+		if evaluated == nil {
+			t.Errorf("%q evaluated to nil", tt.Input)
+			continue
+		}
+
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCorpusTraces(t *testing.T) {
+	for _, tt := range corpus.Traces {
+		err, ok := testEval(tt.Input).(*object.Error)
+		if !ok {
+			t.Errorf("%q did not fail", tt.Input)
+			continue
+		}
+
+		if err.Trace() != tt.Trace {
+			t.Errorf("wrong trace for %q. want=%q, got=%q", tt.Input, tt.Trace, err.Trace())
+		}
+	}
+}
+
+func TestCorpusBudgets(t *testing.T) {
+	for _, tt := range corpus.Budgets {
+		l := lexer.New(tt.Input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.Cancelled {
+			cancel()
+		}
+
+		e := New()
+		e.MaxSteps = tt.MaxSteps
+		e.MaxAllocations = tt.MaxAllocations
+
+		evaluated, _ := e.EvalContext(ctx, program, object.NewEnvironment())
+		cancel()
+
+		if evaluated.Inspect() != tt.Expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.Input, tt.Expected, evaluated.Inspect())
+		}
+	}
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -535,6 +1351,54 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+// errorMessage is the expected message of an error in table driven tests.
+type errorMessage string
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, result.Message)
+		return false
+	}
+
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -544,6 +1408,24 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	return true
 }
 
+// testEvalFiles evaluates input as the file main.apa with e, reading the
+// files it imports from files.
+func testEvalFiles(e *Evaluator, files map[string]string, input string) object.Object {
+	e.ReadFile = func(name string) ([]byte, error) {
+		src, ok := files[filepath.ToSlash(name)]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(src), nil
+	}
+
+	l := lexer.NewWithFilename("main.apa", input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	return e.EvalFile("main.apa", program, object.NewEnvironment())
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -552,3 +1434,16 @@ func testEval(input string) object.Object {
 
 	return Eval(program, env)
 }
+
+func TestParseOverflowMode(t *testing.T) {
+	for name, expected := range map[string]OverflowMode{"promote": OverflowPromote, "wrap": OverflowWrap, "error": OverflowError} {
+		mode, err := ParseOverflowMode(name)
+		if err != nil || mode != expected {
+			t.Errorf("ParseOverflowMode(%q) = %d, %v, want=%d", name, mode, err, expected)
+		}
+	}
+
+	if _, err := ParseOverflowMode("saturate"); err == nil {
+		t.Errorf("expected an error for an unknown mode")
+	}
+}
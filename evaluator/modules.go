@@ -0,0 +1,177 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+)
+
+// ErrImportsDisabled is the error of NoImports.
+var ErrImportsDisabled = errors.New("importera is disabled")
+
+// NoImports is a ReadFile that refuses every file, so that programs cannot
+// use importera.
+func NoImports(name string) ([]byte, error) {
+	return nil, ErrImportsDisabled
+}
+
+// ImportsFrom returns a ReadFile that reads only the files in root and the
+// directories in it, after following symbolic links. Relative paths are
+// relative to root.
+func ImportsFrom(root string) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(root, name)
+		}
+
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			return nil, err
+		}
+
+		realName, err := filepath.EvalSymlinks(name)
+		if err != nil {
+			return nil, err
+		}
+
+		rel, err := filepath.Rel(realRoot, realName)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside of %s", name, root)
+		}
+
+		return os.ReadFile(realName)
+	}
+}
+
+// EvalFile evaluates program, read from filename, in env. The file counts as
+// imported while it runs, so that a module importing it back is reported as
+// an import cycle rather than evaluated a second time.
+func (e *Evaluator) EvalFile(filename string, program *ast.Program, env *object.Environment) object.Object {
+	if key, err := filepath.Abs(filename); err == nil {
+		e.importing = append(e.importing, key)
+		defer func() { e.importing = e.importing[:len(e.importing)-1] }()
+	}
+
+	return e.Eval(program, env)
+}
+
+// evalImportStatement binds the module of the file imported to its name. The
+// path is relative to the file the statement is in, or to the working
+// directory if it is not in a file. Each file is evaluated once per Evaluator,
+// importing it again gives the same module.
+func (e *Evaluator) evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := e.importModule(node)
+	if isError(module) {
+		return module
+	}
+
+	env.Set(node.Name.Value, module)
+
+	return nil
+}
+
+func (e *Evaluator) importModule(node *ast.ImportStatement) object.Object {
+	path := node.Path.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(node.Token.Span.Start.Filename), path)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return newKindError(object.IMPORT_ERROR_KIND, "cannot import %q: %s", node.Path.Value, err)
+	}
+
+	if module, ok := e.modules[key]; ok {
+		return module
+	}
+
+	for i, importing := range e.importing {
+		if importing == key {
+			cycle := append(append([]string{}, e.importing[i:]...), key)
+			return importCycleError(cycle)
+		}
+	}
+
+	readFile := e.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+
+	src, err := readFile(path)
+	if err != nil {
+		return newKindError(object.IMPORT_ERROR_KIND, "cannot import %q: %s", node.Path.Value, err)
+	}
+
+	p := parser.New(lexer.NewWithFilename(path, string(src)))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newKindError(object.IMPORT_ERROR_KIND, "cannot import %q: %s", node.Path.Value, p.Errors()[0])
+	}
+
+	e.importing = append(e.importing, key)
+	defer func() { e.importing = e.importing[:len(e.importing)-1] }()
+
+	module := &object.Module{
+		Name:    node.Name.Value,
+		Path:    node.Path.Value,
+		Env:     object.NewEnvironment(),
+		Exports: map[string]bool{},
+	}
+
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			module.Exports[let.Name.Value] = true
+		}
+	}
+
+	if err, ok := e.eval(program, module.Env).(*object.Error); ok {
+		frame := object.StackFrame{Function: fmt.Sprintf("importera %q", node.Path.Value), Call: node.Span().Start}
+		err.Stack = append(err.Stack, frame)
+		return err
+	}
+
+	if e.modules == nil {
+		e.modules = map[string]*object.Module{}
+	}
+	e.modules[key] = module
+
+	return module
+}
+
+// importCycleError reports the files of an import cycle in the order they
+// import each other.
+func importCycleError(cycle []string) *object.Error {
+	names := make([]string, len(cycle))
+	for i, path := range cycle {
+		names[i] = filepath.Base(path)
+	}
+
+	return newKindError(object.IMPORT_ERROR_KIND, "import cycle: %s", strings.Join(names, " -> "))
+}
+
+func (e *Evaluator) evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := e.eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newKindError(object.TYPE_ERROR_KIND, "member access not supported: %s.%s", obj.Type(), node.Member.Value)
+	}
+
+	value, ok := module.Get(node.Member.Value)
+	if !ok {
+		return newKindError(object.NAME_ERROR_KIND, "module %s has no binding %s", module.Name, node.Member.Value)
+	}
+
+	return value
+}
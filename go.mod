@@ -1,3 +1,7 @@
 module github.com/oliversabler/apa
 
 go 1.21.6
+
+require golang.org/x/term v0.20.0
+
+require golang.org/x/sys v0.20.0 // indirect
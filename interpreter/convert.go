@@ -0,0 +1,425 @@
+package interpreter
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/oliversabler/apa/evaluator"
+	"github.com/oliversabler/apa/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf((*big.Int)(nil))
+)
+
+// ToObject converts a Go value to an Apa value. Integers, floats, strings and
+// bools convert to their Apa counterparts, slices and arrays to arrays, maps
+// to hashes and funcs to builtins, see WrapFunc. Structs convert to hashes
+// keyed by the names of their exported fields, which the tag apa:"namn"
+// renames and apa:"-" leaves out. Nil and nil pointers convert to null.
+func ToObject(value interface{}) (object.Object, error) {
+	if value == nil {
+		return evaluator.NULL, nil
+	}
+
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+	}
+
+	switch value := v.Interface().(type) {
+	case object.Object:
+		return value, nil
+	case *big.Int:
+		return object.NewInteger(new(big.Int).Set(value)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return object.NewInteger(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, v.Len())}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := setPair(hash, iter.Key(), iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		for _, field := range fields(v.Type()) {
+			if err := setPair(hash, reflect.ValueOf(field.key), v.FieldByIndex(field.index)); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Pointer, reflect.Interface:
+		return toObject(v.Elem())
+	case reflect.Func:
+		return WrapFunc(v.Interface())
+	default:
+		return nil, fmt.Errorf("cannot convert %s to an Apa value", v.Type())
+	}
+}
+
+func setPair(hash *object.Hash, key, value reflect.Value) error {
+	k, err := toObject(key)
+	if err != nil {
+		return err
+	}
+
+	hashable, ok := k.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", k.Type())
+	}
+
+	val, err := toObject(value)
+	if err != nil {
+		return err
+	}
+
+	hash.Pairs[hashable.HashKey()] = object.HashPair{Key: k, Value: val}
+
+	return nil
+}
+
+// FromObject converts an Apa value to the Go value target points to, the
+// reverse of ToObject. Keys of a hash that are not fields of a target struct
+// are ignored. An interface{} target gets int64, *big.Int, float64, string,
+// bool, nil, []interface{} or map[string]interface{}, the latter being
+// map[interface{}]interface{} for hashes with keys other than strings.
+func FromObject(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target of FromObject must be a non-nil pointer, got %T", target)
+	}
+
+	return fromObject(obj, v.Elem())
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	t := v.Type()
+
+	if t == objectType {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == object.NULL_OBJ {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+
+	if t == bigIntType && object.IsInteger(obj) {
+		v.Set(reflect.ValueOf(toBigInt(obj)))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if t.NumMethod() != 0 {
+			break
+		}
+		value, err := toGo(obj)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+	case reflect.Bool:
+		if boolean, ok := obj.(*object.Boolean); ok {
+			v.SetBool(boolean.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.Integer); ok {
+			if v.OverflowInt(integer.Value) {
+				return fmt.Errorf("%d overflows %s", integer.Value, t)
+			}
+			v.SetInt(integer.Value)
+			return nil
+		}
+		if obj.Type() == object.BIG_INTEGER_OBJ {
+			return fmt.Errorf("%s overflows %s", obj.Inspect(), t)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if object.IsInteger(obj) {
+			value := toBigInt(obj)
+			if !value.IsUint64() || v.OverflowUint(value.Uint64()) {
+				return fmt.Errorf("%s overflows %s", obj.Inspect(), t)
+			}
+			v.SetUint(value.Uint64())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch number := obj.(type) {
+		case *object.Float:
+			v.SetFloat(number.Value)
+			return nil
+		case *object.Integer:
+			v.SetFloat(float64(number.Value))
+			return nil
+		case *object.BigInt:
+			v.SetFloat(object.BigToFloat(number))
+			return nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			v.SetString(str.Value)
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+		} else if v.Len() != len(array.Elements) {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(array.Elements), t)
+		}
+		for i, element := range array.Elements {
+			if err := fromObject(element, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return err
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromObject(pair.Value, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		for _, field := range fields(t) {
+			key := &object.String{Value: field.key}
+			pair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, v.FieldByIndex(field.index)); err != nil {
+				return fmt.Errorf("field %s: %w", field.key, err)
+			}
+		}
+		return nil
+	case reflect.Pointer:
+		value := reflect.New(t.Elem())
+		if err := fromObject(obj, value.Elem()); err != nil {
+			return err
+		}
+		v.Set(value)
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+// toGo converts obj to the Go value it is most naturally represented by, see
+// FromObject. Other values, such as functions, are kept as they are.
+func toGo(obj object.Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.BigInt:
+		return toBigInt(obj), nil
+	case *object.Float:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toGo(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		var target interface{} = map[string]interface{}{}
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != object.STRING_OBJ {
+				target = map[interface{}]interface{}{}
+				break
+			}
+		}
+		v := reflect.New(reflect.TypeOf(target)).Elem()
+		if err := fromObject(obj, v); err != nil {
+			return nil, err
+		}
+		return v.Interface(), nil
+	default:
+		return obj, nil
+	}
+}
+
+// toBigInt copies the value of an Integer or BigInt to a new big.Int.
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+
+	return new(big.Int).Set(obj.(*object.BigInt).Value)
+}
+
+// WrapFunc makes a builtin of the Go func fn. The arguments of the builtin
+// are converted to the parameters of fn with FromObject, and the results of
+// fn back with ToObject: none gives null, one gives its value and several
+// give an array. A non-nil error as the last result is raised as an error.
+func WrapFunc(fn interface{}) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("cannot wrap %T as a builtin, it is not a func", fn)
+	}
+
+	t := v.Type()
+
+	numResults := t.NumOut()
+	returnsError := numResults > 0 && t.Out(numResults-1) == errorType
+	if returnsError {
+		numResults--
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		numIn := t.NumIn()
+		if t.IsVariadic() && len(args) < numIn-1 {
+			return newArgumentError("wrong number of arguments. got=%d, want at least %d", len(args), numIn-1)
+		}
+		if !t.IsVariadic() && len(args) != numIn {
+			return newArgumentError("wrong number of arguments. got=%d, want=%d", len(args), numIn)
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			paramType := t.In(min(i, numIn-1))
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = paramType.Elem()
+			}
+
+			in[i] = reflect.New(paramType).Elem()
+			if err := fromObject(arg, in[i]); err != nil {
+				return newArgumentError("argument %d: %s", i+1, err)
+			}
+		}
+
+		out := v.Call(in)
+
+		if returnsError && !out[numResults].IsNil() {
+			err := out[numResults].Interface().(error)
+			return &object.Error{Message: err.Error(), Kind: object.ERROR_KIND}
+		}
+
+		results := make([]object.Object, numResults)
+		for i := range results {
+			result, err := toObject(out[i])
+			if err != nil {
+				return &object.Error{Message: err.Error(), Kind: object.TYPE_ERROR_KIND}
+			}
+			results[i] = result
+		}
+
+		switch numResults {
+		case 0:
+			return evaluator.NULL
+		case 1:
+			return results[0]
+		default:
+			return &object.Array{Elements: results}
+		}
+	}}, nil
+}
+
+func newArgumentError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.ARGUMENT_ERROR_KIND}
+}
+
+type field struct {
+	key   string
+	index []int
+}
+
+// fields lists the exported fields of a struct type by the key they have in
+// a hash.
+func fields(t reflect.Type) []field {
+	var fs []field
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		key := f.Name
+		if tag, ok := f.Tag.Lookup("apa"); ok {
+			name, _, _ := strings.Cut(tag, ",")
+			if name == "-" {
+				continue
+			}
+			if name != "" {
+				key = name
+			}
+		}
+
+		fs = append(fs, field{key: key, index: f.Index})
+	}
+
+	return fs
+}
@@ -0,0 +1,190 @@
+package interpreter
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/oliversabler/apa/object"
+)
+
+type config struct {
+	Name    string `apa:"namn"`
+	Port    int    `apa:"port"`
+	Debug   bool
+	Tags    []string          `apa:"taggar"`
+	Limits  map[string]uint16 `apa:"gränser"`
+	Secret  string            `apa:"-"`
+	private int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{big.NewInt(7), "7"},
+		{1.5, "1.5"},
+		{"hej", "hej"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{(*int)(nil), "null"},
+		{&config{Port: 8080}, "{Debug: false, gränser: null, namn: , port: 8080, taggar: null}"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.value)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %s", tt.value, err)
+			continue
+		}
+
+		if inspected := inspectSorted(obj); inspected != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.value, tt.expected, inspected)
+		}
+	}
+
+	if _, err := ToObject(make(chan int)); err == nil {
+		t.Errorf("expected error converting a channel")
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	interp := New()
+
+	result, err := interp.Eval(`{"namn": "apa", "port": 8080, "Debug": sant, "taggar": ["a", "b"],
+		"gränser": {"anslutningar": 10}, "Secret": "hemligt", "okänd": 1}`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	var c config
+	if err := FromObject(result, &c); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+
+	expected := config{
+		Name:   "apa",
+		Port:   8080,
+		Debug:  true,
+		Tags:   []string{"a", "b"},
+		Limits: map[string]uint16{"anslutningar": 10},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("wrong config. want=%+v, got=%+v", expected, c)
+	}
+
+	var value interface{}
+	if err := FromObject(result, &value); err != nil {
+		t.Fatalf("FromObject failed: %s", err)
+	}
+	if m, ok := value.(map[string]interface{}); !ok || m["port"] != int64(8080) {
+		t.Errorf("wrong interface{} value. got=%#v", value)
+	}
+
+	var small int8
+	if err := FromObject(&object.Integer{Value: 300}, &small); err == nil {
+		t.Errorf("expected overflow error converting 300 to int8")
+	}
+
+	var unsigned uint
+	if err := FromObject(&object.Integer{Value: -1}, &unsigned); err == nil {
+		t.Errorf("expected error converting -1 to uint")
+	}
+
+	var s string
+	if err := FromObject(&object.Integer{Value: 1}, &s); err == nil || err.Error() != "cannot convert INTEGER to string" {
+		t.Errorf("wrong error converting INTEGER to string. got=%v", err)
+	}
+
+	if err := FromObject(&object.Integer{Value: 1}, s); err == nil {
+		t.Errorf("expected error for a target that is not a pointer")
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	interp := New()
+
+	funcs := map[string]interface{}{
+		"versaler": strings.ToUpper,
+		"summa": func(tal ...int) int {
+			sum := 0
+			for _, n := range tal {
+				sum += n
+			}
+			return sum
+		},
+		"dela": func(a, b int) (int, int, error) {
+			if b == 0 {
+				return 0, 0, errors.New("dela med noll")
+			}
+			return a / b, a % b, nil
+		},
+	}
+	for name, fn := range funcs {
+		if err := interp.RegisterFunc(name, fn); err != nil {
+			t.Fatalf("RegisterFunc(%s) failed: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`versaler("apa")`, "APA"},
+		{"summa()", "0"},
+		{"summa(1, 2, 3)", "6"},
+		{"dela(7, 2)", "[3, 1]"},
+		{"dela(7, 0)", "dela med noll"},
+		{"dela(7)", "wrong number of arguments. got=1, want=2"},
+		{`summa(1, "två")`, "argument 2: cannot convert STRING to int"},
+		{`försök { dela(1, 0) } fånga (fel) { fel["meddelande"] }`, "dela med noll"},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Eval(tt.input)
+
+		var got string
+		var runtimeError *RuntimeError
+		switch {
+		case errors.As(err, &runtimeError):
+			got = runtimeError.Err.Message
+		case err != nil:
+			t.Errorf("Eval(%q) failed: %s", tt.input, err)
+			continue
+		default:
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	if _, err := WrapFunc(42); err == nil {
+		t.Errorf("expected error wrapping a non-func")
+	}
+}
+
+// inspectSorted is Inspect with the pairs of hashes in key order.
+func inspectSorted(obj object.Object) string {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return obj.Inspect()
+	}
+
+	pairs := []string{}
+	for _, pair := range hash.SortedPairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+inspectSorted(pair.Value))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,37 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+)
+
+// ParseError is returned when the source of a program does not parse.
+type ParseError struct {
+	Errors []*parser.ParseError
+}
+
+func (pe *ParseError) Error() string {
+	messages := make([]string, len(pe.Errors))
+	for i, err := range pe.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is returned when a program stops with an error that it did
+// not catch.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (re *RuntimeError) Error() string {
+	if re.Err.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", re.Err.Pos, re.Err.Message)
+	}
+
+	return re.Err.Message
+}
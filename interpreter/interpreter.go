@@ -0,0 +1,122 @@
+// Package interpreter embeds Apa in Go programs. Each Interpreter has its own
+// globals and builtins, so several of them with different capabilities can
+// run side by side.
+package interpreter
+
+import (
+	"context"
+
+	"github.com/oliversabler/apa/evaluator"
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+)
+
+type Interpreter struct {
+	// Evaluator runs the programs. Its settings, such as the budgets, may be
+	// changed between calls to Eval.
+	Evaluator *evaluator.Evaluator
+
+	env *object.Environment
+}
+
+// Option changes a setting of an Interpreter made by New.
+type Option func(*Interpreter)
+
+// WithOverflow sets what happens when integer arithmetic overflows, by
+// default the result is promoted to a big integer.
+func WithOverflow(mode evaluator.OverflowMode) Option {
+	return func(i *Interpreter) {
+		i.Evaluator.Overflow = mode
+	}
+}
+
+// WithImports lets programs import the files in root and the directories in
+// it with importera, by default they cannot import any. Relative paths are
+// relative to root.
+func WithImports(root string) Option {
+	return func(i *Interpreter) {
+		i.Evaluator.ReadFile = evaluator.ImportsFrom(root)
+	}
+}
+
+// New returns an Interpreter with no globals, the default builtins and
+// importera disabled.
+func New(options ...Option) *Interpreter {
+	e := evaluator.New()
+	e.Builtins = evaluator.DefaultBuiltins()
+	e.ReadFile = evaluator.NoImports
+
+	i := &Interpreter{Evaluator: e, env: object.NewEnvironment()}
+	for _, option := range options {
+		option(i)
+	}
+
+	return i
+}
+
+// RegisterBuiltin makes fn callable by programs as name, replacing any
+// builtin of the same name.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.Evaluator.Builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// RegisterFunc makes the Go func fn callable by programs as name, with its
+// arguments and results converted as by WrapFunc.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := WrapFunc(fn)
+	if err != nil {
+		return err
+	}
+
+	i.Evaluator.Builtins[name] = builtin
+
+	return nil
+}
+
+// RemoveBuiltin takes the builtin name away from programs.
+func (i *Interpreter) RemoveBuiltin(name string) {
+	delete(i.Evaluator.Builtins, name)
+}
+
+// Get returns the value of the global name.
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// Set binds the global name to value, as låt would.
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+// Eval runs src in the globals of the interpreter and returns the value of
+// its last statement, or NULL if it has none. Errors are a *ParseError, a
+// *RuntimeError or the cause of a stopped evaluation, see
+// evaluator.EvalContext.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval, stopping when ctx is done.
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	result, err := i.Evaluator.EvalContext(ctx, program, i.env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result := result.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case *object.Error:
+		return nil, &RuntimeError{Err: result}
+	default:
+		return result, nil
+	}
+}
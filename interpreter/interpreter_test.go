@@ -0,0 +1,198 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oliversabler/apa/evaluator"
+	"github.com/oliversabler/apa/object"
+)
+
+func TestEval(t *testing.T) {
+	interp := New()
+
+	result, err := interp.Eval("låt a = 2; a * 21")
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	result, err = interp.Eval("låt b = a")
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result != evaluator.NULL {
+		t.Errorf("låt did not evaluate to NULL. got=%s", result.Inspect())
+	}
+
+	if b, ok := interp.Get("b"); !ok || b.Inspect() != "2" {
+		t.Errorf("global b not kept between calls. got=%v", b)
+	}
+}
+
+func TestSet(t *testing.T) {
+	interp := New()
+	interp.Set("namn", &object.String{Value: "apa"})
+
+	result, err := interp.Eval(`"hej " + namn`)
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result.Inspect() != "hej apa" {
+		t.Errorf("wrong result. want=%q, got=%q", "hej apa", result.Inspect())
+	}
+}
+
+func TestRegisterBuiltin(t *testing.T) {
+	doubled := New()
+	doubled.RegisterBuiltin("dubbla", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 2 * args[0].(*object.Integer).Value}
+	})
+	doubled.RemoveBuiltin("skriv")
+
+	result, err := doubled.Eval("dubbla(21)")
+	if err != nil {
+		t.Fatalf("Eval failed: %s", err)
+	}
+
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	if _, err := doubled.Eval(`skriv("hej")`); err == nil {
+		t.Errorf("removed builtin skriv still callable")
+	}
+
+	if _, err := New().Eval("dubbla(21)"); err == nil {
+		t.Errorf("builtin registered on one interpreter is callable from another")
+	}
+
+	if _, err := New().Eval("längd([1])"); err != nil {
+		t.Errorf("default builtin längd not callable: %s", err)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"låt = 1", "1:5: expected next token to be IDENT, got=="},
+		{"1 +\nx", "2:1: identifier not found: x"},
+		{"låt f = funktion(a, b) { a };\nf(1)", "2:1: wrong number of arguments: want=2, got=1"},
+	}
+
+	for _, tt := range tests {
+		_, err := New().Eval(tt.input)
+		if err == nil {
+			t.Errorf("expected error for %q", tt.input)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+
+	var runtimeError *RuntimeError
+	if _, err := New().Eval("kasta fel(\"oj\", \"typfel\")"); !errors.As(err, &runtimeError) {
+		t.Errorf("uncaught kasta is not a *RuntimeError. got=%T", err)
+	}
+
+	interp := New()
+	interp.Evaluator.MaxSteps = 10
+	if _, err := interp.Eval("medan (sant) { }"); !errors.Is(err, evaluator.ErrStepBudget) {
+		t.Errorf("expected ErrStepBudget. got=%v", err)
+	}
+}
+
+func TestWithOverflow(t *testing.T) {
+	tests := []struct {
+		mode     evaluator.OverflowMode
+		expected string
+		err      string
+	}{
+		{evaluator.OverflowPromote, "9223372036854775808", ""},
+		{evaluator.OverflowWrap, "-9223372036854775808", ""},
+		{evaluator.OverflowError, "", "1:1: integer overflow: 9223372036854775807 + 1"},
+	}
+
+	for _, tt := range tests {
+		result, err := New(WithOverflow(tt.mode)).Eval("9223372036854775807 + 1")
+
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("wrong error. want=%q, got=%v", tt.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("Eval failed: %s", err)
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result. want=%s, got=%s", tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestWithImports(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "rot")
+
+	files := map[string]string{
+		filepath.Join(root, "matte.apa"):       "låt dubbel = funktion(x) { x * 2 };",
+		filepath.Join(root, "lib", "svar.apa"): `importera "../matte.apa"; låt svar = matte.dubbel(21);`,
+		filepath.Join(dir, "hemlig.apa"):       "låt hemlighet = 42;",
+	}
+	for name, src := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(filepath.Join(dir, "hemlig.apa"), filepath.Join(root, "länk.apa")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		interp   *Interpreter
+		input    string
+		expected string
+	}{
+		{New(), `importera "matte.apa"`, `cannot import "matte.apa": importera is disabled`},
+		{New(WithImports(root)), `importera "lib/svar.apa"; svar.svar`, "42"},
+		{New(WithImports(root)), `importera "../hemlig.apa"`, `cannot import "../hemlig.apa": ` + filepath.Join(root, "../hemlig.apa") + " is outside of " + root},
+		{New(WithImports(root)), `importera "länk.apa"`, `cannot import "länk.apa": ` + filepath.Join(root, "länk.apa") + " is outside of " + root},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.interp.Eval(tt.input)
+
+		var got string
+		if err != nil {
+			var runtimeError *RuntimeError
+			if !errors.As(err, &runtimeError) {
+				t.Fatalf("wrong error for %q. got=%T (%s)", tt.input, err, err)
+			}
+			got = runtimeError.Err.Message
+		} else {
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
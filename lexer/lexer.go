@@ -1,26 +1,51 @@
 package lexer
 
-import "github.com/oliversabler/apa/token"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/oliversabler/apa/token"
+)
 
 type Lexer struct {
+	filename     string
 	input        string
 	position     int
 	readPosition int
-	ch           byte
+	ch           rune
+	line         int
+	column       int
+
+	keepComments bool
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
 
 	return l
 }
 
+// KeepComments makes NextToken return comments as COMMENT tokens instead of
+// skipping them, for tools that need to preserve them.
+func (l *Lexer) KeepComments(keep bool) {
+	l.keepComments = keep
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	start := l.currentPosition()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -41,20 +66,83 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, err := l.readString()
+		if err != nil {
+			tok = errorToken(err)
+		} else {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		}
+	case '`':
+		literal, err := l.readRawString()
+		if err != nil {
+			tok = errorToken(err)
+		} else {
+			tok = token.Token{Type: token.STRING, Literal: literal}
+		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.MINUS_ASSIGN)
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.SLASH_ASSIGN)
+		case '/':
+			tok = token.Token{Type: token.COMMENT, Literal: l.readLineComment()}
+		case '*':
+			literal, err := l.readBlockComment()
+			if err != nil {
+				tok = errorToken(err)
+			} else {
+				tok = token.Token{Type: token.COMMENT, Literal: literal}
+			}
+		default:
+			tok = newToken(token.SLASH, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.ch)
+		}
+	case '%':
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.PERCENT_ASSIGN)
+		} else {
+			tok = newToken(token.PERCENT, l.ch)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.LTE)
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(token.GTE)
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ':':
@@ -65,6 +153,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -80,10 +170,11 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Span = l.spanFrom(start)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Span = l.spanFrom(start)
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -93,25 +184,77 @@ func (l *Lexer) NextToken() token.Token {
 
 	l.readChar()
 
+	if tok.Type == token.COMMENT && !l.keepComments {
+		return l.NextToken()
+	}
+
+	tok.Span = l.spanFrom(start)
+
 	return tok
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) readTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+func (l *Lexer) spanFrom(start token.Position) token.Span {
+	return token.Span{Start: start, End: l.currentPosition()}
+}
+
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+
+	return ch
+}
+
+func (l *Lexer) peekSecondChar() rune {
+	if l.readPosition >= len(l.input) {
+		return 0
+	}
+
+	_, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	if l.readPosition+width >= len(l.input) {
+		return 0
+	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition+width:])
+
+	return ch
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
+	l.position = l.readPosition
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		return
 	}
-	l.position = l.readPosition
-	l.readPosition += 1
+
+	ch, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = ch
+	l.readPosition += width
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -123,26 +266,176 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float. A float has a fraction, an
+// exponent or both, e.g. 1.5, 2e10 and 6.02e-23. The dot and the exponent
+// are only consumed when followed by a digit, so 1.x and 1e are not floats.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if (next == '+' || next == '-') && isDigit(l.peekSecondChar()) {
+			tokenType = token.FLOAT
+			l.readChar()
+			l.readChar()
+			l.readDigits()
+		} else if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// readString reads a double quoted string and resolves its escape sequences.
+// The string may span lines, its newlines are kept. After an invalid escape
+// the rest of the string is still consumed so that lexing resumes after the
+// closing quote.
+func (l *Lexer) readString() (string, error) {
+	var out strings.Builder
+	var escapeErr error
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			if escapeErr != nil {
+				return "", escapeErr
+			}
+			return out.String(), nil
+		case 0:
+			return "", unterminatedError("string")
+		case '\\':
+			if l.peekChar() == 0 {
+				return "", unterminatedError("string")
+			}
+
+			l.readChar()
+			ch, err := l.readEscape()
+			if err != nil {
+				if escapeErr == nil {
+					escapeErr = err
+				}
+				continue
+			}
+			out.WriteRune(ch)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape resolves the escape sequence starting at the character after the
+// backslash. It leaves l.ch on the last character of the sequence.
+func (l *Lexer) readEscape() (rune, error) {
+	switch l.ch {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '"':
+		return '"', nil
+	case '\\':
+		return '\\', nil
+	case 'u':
+		return l.readUnicodeEscape()
+	case '\n':
+		return 0, fmt.Errorf("invalid escape sequence \\ at end of line")
+	default:
+		return 0, fmt.Errorf("invalid escape sequence \\%c", l.ch)
+	}
+}
+
+func (l *Lexer) readUnicodeEscape() (rune, error) {
+	if l.peekChar() != '{' {
+		return 0, fmt.Errorf("invalid unicode escape, expected \\u{...}")
+	}
+	l.readChar()
+
+	position := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[position:l.readPosition]
+
+	if l.peekChar() != '}' {
+		return 0, fmt.Errorf("invalid unicode escape, expected \\u{...}")
+	}
+	l.readChar()
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+		return 0, fmt.Errorf("invalid unicode code point \\u{%s}", digits)
+	}
+
+	return rune(value), nil
 }
 
-func (l *Lexer) readString() string {
-	// TODO: Support character escape
+func (l *Lexer) readRawString() (string, error) {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '`' {
+			return l.input[position:l.position], nil
+		}
+		if l.ch == 0 {
+			return "", unterminatedError("raw string")
 		}
 	}
+}
 
-	return l.input[position:l.position]
+func (l *Lexer) readLineComment() string {
+	position := l.position
+	for l.peekChar() != '\n' && l.peekChar() != 0 {
+		l.readChar()
+	}
+
+	return l.input[position:l.readPosition]
+}
+
+// readBlockComment reads a /* ... */ comment. Block comments nest, so a
+// commented out region may itself contain block comments.
+func (l *Lexer) readBlockComment() (string, error) {
+	position := l.position
+	depth := 0
+
+	for {
+		switch {
+		case l.ch == 0:
+			return "", unterminatedError("comment")
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readChar()
+			depth++
+		case l.ch == '*' && l.peekChar() == '/':
+			l.readChar()
+			depth--
+			if depth == 0 {
+				return l.input[position:l.readPosition], nil
+			}
+		}
+
+		l.readChar()
+	}
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -151,21 +444,36 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func isLetter(ch byte) bool {
-	// Note:
-	//  The byte code representation is not solid because of characters å, ä, ö
-	//  The if statement below is a "temporary" fix
-	if ch == '[' || ch == ']' {
-		return false
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+// unterminatedError is the error of a string or comment that the input ends
+// inside of.
+type unterminatedError string
+
+func (e unterminatedError) Error() string {
+	return "unterminated " + string(e)
+}
+
+// errorToken is the ERROR token of err, or the UNTERMINATED token if the
+// input ended too early.
+func errorToken(err error) token.Token {
+	if _, ok := err.(unterminatedError); ok {
+		return token.Token{Type: token.UNTERMINATED, Literal: err.Error()}
 	}
 
-	return 'a' <= ch && ch <= 'ö' || 'A' <= ch && ch <= 'Ö' || ch == '_'
+	return token.Token{Type: token.ERROR, Literal: err.Error()}
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
@@ -13,7 +13,7 @@ låt addera = funktion(x, y) {
      x + y;
 };
 låt resultat = addera(fem, tio);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 om (5 < 10) {
    tillbaka sant;
@@ -26,7 +26,8 @@ om (5 < 10) {
 "foobar"
 "foo bar"
 låt arr = [1, 2]; arr[1];
-{"foo": "bar"}`
+{"foo": "bar"}
+importera "matte.apa"; matte.pi`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -126,7 +127,437 @@ låt arr = [1, 2]; arr[1];
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.IMPORT, "importera"},
+		{token.STRING, "matte.apa"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "matte"},
+		{token.DOT, "."},
+		{token.IDENT, "pi"},
+
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenUnicode(t *testing.T) {
+	input := `låt äpple = "räksmörgås";
+låt öl = Ärlig + Östen;
+låt café = "crème brûlée";
+låt straße = größe;
+låt π = "αβγ";
+låt 名前 = "日本語";
+x[ö]`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "låt"},
+		{token.IDENT, "äpple"},
+		{token.ASSIGN, "="},
+		{token.STRING, "räksmörgås"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "öl"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "Ärlig"},
+		{token.PLUS, "+"},
+		{token.IDENT, "Östen"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "café"},
+		{token.ASSIGN, "="},
+		{token.STRING, "crème brûlée"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "straße"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "größe"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "π"},
+		{token.ASSIGN, "="},
+		{token.STRING, "αβγ"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "名前"},
+		{token.ASSIGN, "="},
+		{token.STRING, "日本語"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.LBRACKET, "["},
+		{token.IDENT, "ö"},
+		{token.RBRACKET, "]"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
 
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+	}{
+		{"@", "@"},
+		{"€", "€"},
+		{"→", "→"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.ILLEGAL {
+			t.Errorf("tokentype wrong. expected=%q, got=%q", token.ILLEGAL, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("literal wrong. expected=%q, got=%q", tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("expected EOF after %q. got=%q", tt.input, next.Type)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "låt ål = 5;\nom (ål > 1) {\n\t\"två\nrader\"\n}"
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 4, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 5, Line: 1, Column: 5}, token.Position{Offset: 8, Line: 1, Column: 7}},
+		{token.ASSIGN, token.Position{Offset: 9, Line: 1, Column: 8}, token.Position{Offset: 10, Line: 1, Column: 9}},
+		{token.INT, token.Position{Offset: 11, Line: 1, Column: 10}, token.Position{Offset: 12, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 12, Line: 1, Column: 11}, token.Position{Offset: 13, Line: 1, Column: 12}},
+		{token.IF, token.Position{Offset: 14, Line: 2, Column: 1}, token.Position{Offset: 16, Line: 2, Column: 3}},
+		{token.LPAREN, token.Position{Offset: 17, Line: 2, Column: 4}, token.Position{Offset: 18, Line: 2, Column: 5}},
+		{token.IDENT, token.Position{Offset: 18, Line: 2, Column: 5}, token.Position{Offset: 21, Line: 2, Column: 7}},
+		{token.GT, token.Position{Offset: 22, Line: 2, Column: 8}, token.Position{Offset: 23, Line: 2, Column: 9}},
+		{token.INT, token.Position{Offset: 24, Line: 2, Column: 10}, token.Position{Offset: 25, Line: 2, Column: 11}},
+		{token.RPAREN, token.Position{Offset: 25, Line: 2, Column: 11}, token.Position{Offset: 26, Line: 2, Column: 12}},
+		{token.LBRACE, token.Position{Offset: 27, Line: 2, Column: 13}, token.Position{Offset: 28, Line: 2, Column: 14}},
+		{token.STRING, token.Position{Offset: 30, Line: 3, Column: 2}, token.Position{Offset: 42, Line: 4, Column: 7}},
+		{token.RBRACE, token.Position{Offset: 43, Line: 5, Column: 1}, token.Position{Offset: 44, Line: 5, Column: 2}},
+		{token.EOF, token.Position{Offset: 44, Line: 5, Column: 2}, token.Position{Offset: 44, Line: 5, Column: 3}},
+	}
+
+	l := NewWithFilename("test.apa", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		tt.expectedStart.Filename = "test.apa"
+		tt.expectedEnd.Filename = "test.apa"
+
+		if tok.Span.Start != tt.expectedStart {
+			t.Errorf("tests[%d] - start wrong. expected=%+v, got=%+v",
+				i, tt.expectedStart, tok.Span.Start)
+		}
+
+		if tok.Span.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v",
+				i, tt.expectedEnd, tok.Span.End)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"hej"`, token.STRING, "hej"},
+		{`""`, token.STRING, ""},
+		{`"rad\nrad"`, token.STRING, "rad\nrad"},
+		{`"a\tb\rc"`, token.STRING, "a\tb\rc"},
+		{`"säg \"hej\""`, token.STRING, `säg "hej"`},
+		{`"C:\\apa"`, token.STRING, `C:\apa`},
+		{`"\u{e5}\u{E4}\u{f6}"`, token.STRING, "åäö"},
+		{`"\u{1F412}"`, token.STRING, "🐒"},
+		{"`rå \\n sträng`", token.STRING, `rå \n sträng`},
+		{"`flera\nrader`", token.STRING, "flera\nrader"},
+		{"``", token.STRING, ""},
+		{`"ingen slut`, token.UNTERMINATED, "unterminated string"},
+		{"\"ny\nrad\"", token.STRING, "ny\nrad"},
+		{"\"ny\\\nrad\"", token.ERROR, "invalid escape sequence \\ at end of line"},
+		{"\"ny\nrad", token.UNTERMINATED, "unterminated string"},
+		{`"slut\`, token.UNTERMINATED, "unterminated string"},
+		{"`ingen slut", token.UNTERMINATED, "unterminated raw string"},
+		{`"\q"`, token.ERROR, `invalid escape sequence \q`},
+		{`"\u00e5"`, token.ERROR, `invalid unicode escape, expected \u{...}`},
+		{`"\u{}"`, token.ERROR, `invalid unicode code point \u{}`},
+		{`"\u{110000}"`, token.ERROR, `invalid unicode code point \u{110000}`},
+		{`"\u{D800}"`, token.ERROR, `invalid unicode code point \u{D800}`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("tokentype wrong for %q. expected=%q, got=%q",
+				tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("literal wrong for %q. expected=%q, got=%q",
+				tt.input, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLexingResumesAfterBadString(t *testing.T) {
+	input := "låt a = \"\\q\"; låt b = \"två\n\\q rader\";\nlåt c = 1;"
+
+	expected := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.ERROR, token.SEMICOLON,
+		token.LET, token.IDENT, token.ASSIGN, token.ERROR, token.SEMICOLON,
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.EOF,
+	}
+
+	l := New(input)
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// en kommentar
+låt x = 10 / 2; // efter kod
+/* block
+   över flera rader */
+låt y /* inne i */ = x;
+/* yttre /* inre */ fortfarande kommentar */
+x //`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "låt"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "låt"},
+		{token.IDENT, "y"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	input := `// rad
+x /* block /* nästlad */ */ y
+/* öppen /* nästlad */`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+	}{
+		{token.COMMENT, "// rad", 1},
+		{token.IDENT, "x", 2},
+		{token.COMMENT, "/* block /* nästlad */ */", 2},
+		{token.IDENT, "y", 2},
+		{token.UNTERMINATED, "unterminated comment", 3},
+		{token.EOF, "", 3},
+	}
+
+	l := New(input)
+	l.KeepComments(true)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Span.Start.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Span.Start.Line)
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	tests := []string{
+		"/* aldrig stängd",
+		"/* /* */",
+		"/*/",
+	}
+
+	for _, input := range tests {
+		l := New(input)
+		tok := l.NextToken()
+
+		if tok.Type != token.UNTERMINATED || tok.Literal != "unterminated comment" {
+			t.Errorf("wrong token for %q. got=%q (%q)", input, tok.Type, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("expected EOF after %q. got=%q", input, next.Type)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"42", []token.Token{{Type: token.INT, Literal: "42"}}},
+		{"3.14", []token.Token{{Type: token.FLOAT, Literal: "3.14"}}},
+		{"0.5", []token.Token{{Type: token.FLOAT, Literal: "0.5"}}},
+		{"1e10", []token.Token{{Type: token.FLOAT, Literal: "1e10"}}},
+		{"2.5E-3", []token.Token{{Type: token.FLOAT, Literal: "2.5E-3"}}},
+		{"7e+2", []token.Token{{Type: token.FLOAT, Literal: "7e+2"}}},
+		{"1.x", []token.Token{
+			{Type: token.INT, Literal: "1"},
+			{Type: token.DOT, Literal: "."},
+			{Type: token.IDENT, Literal: "x"},
+		}},
+		{"3e", []token.Token{
+			{Type: token.INT, Literal: "3"},
+			{Type: token.IDENT, Literal: "e"},
+		}},
+		{"3e-x", []token.Token{
+			{Type: token.INT, Literal: "3"},
+			{Type: token.IDENT, Literal: "e"},
+			{Type: token.MINUS, Literal: "-"},
+			{Type: token.IDENT, Literal: "x"},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+
+		for i, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Errorf("%q tokens[%d] wrong. expected=%s %q, got=%s %q",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `a <= b >= c < d > e % f && g || h och i eller j & |
+x += 1 -= 2 *= 3 /= 4 %= 5`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LTE, "<="},
+		{token.IDENT, "b"},
+		{token.GTE, ">="},
+		{token.IDENT, "c"},
+		{token.LT, "<"},
+		{token.IDENT, "d"},
+		{token.GT, ">"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.AND, "&&"},
+		{token.IDENT, "g"},
+		{token.OR, "||"},
+		{token.IDENT, "h"},
+		{token.AND, "och"},
+		{token.IDENT, "i"},
+		{token.OR, "eller"},
+		{token.IDENT, "j"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "5"},
 		{token.EOF, ""},
 	}
 
@@ -1,11 +1,60 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 
+	"github.com/oliversabler/apa/evaluator"
 	"github.com/oliversabler/apa/repl"
 )
 
 func main() {
-	repl.Start(os.Stdin, os.Stdout)
+	engine := flag.String("engine", "eval", "engine to run programs with: eval or vm, vm does not support importera")
+	overflowName := flag.String("overflow", "promote", "what integer overflow does in the evaluator: promote, wrap or error")
+	expression := flag.String("e", "", "run `code` instead of a file, all arguments go to the program")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: apa [flags] [script.apa] [args ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Fprintf(os.Stderr, "unknown engine %q, want eval or vm\n", *engine)
+		os.Exit(2)
+	}
+
+	overflow, err := evaluator.ParseOverflowMode(*overflowName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// The VM always promotes to big integers
+	if *engine == "vm" && overflow != evaluator.OverflowPromote {
+		fmt.Fprintf(os.Stderr, "-overflow %s needs the evaluator, run with -engine eval\n", *overflowName)
+		os.Exit(2)
+	}
+
+	args := flag.Args()
+
+	switch {
+	case *expression != "":
+		os.Exit(runScript(*engine, overflow, "-e", *expression, args, os.Stderr))
+	case len(args) > 0:
+		src, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(runScript(*engine, overflow, args[0], string(src), args[1:], os.Stderr))
+	case *engine == "vm":
+		repl.StartVM(os.Stdin, os.Stdout)
+	default:
+		repl.StartEvaluator(os.Stdin, os.Stdout, func() *evaluator.Evaluator {
+			e := evaluator.New()
+			e.Overflow = overflow
+			return e
+		})
+	}
 }
@@ -0,0 +1,151 @@
+package object
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// BigInt is an integer that does not fit in an int64. Integer arithmetic
+// promotes to a BigInt on overflow and demotes back to an Integer when the
+// result fits again, so an Integer and a BigInt never hold the same value.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIG_INTEGER_OBJ
+}
+
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte{byte(b.Value.Sign() + 1)})
+	h.Write(b.Value.Bytes())
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// NewInteger returns value as an Integer if it fits in an int64, and as a
+// BigInt otherwise.
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+
+	return &BigInt{Value: value}
+}
+
+// IsInteger reports whether obj is an Integer or a BigInt.
+func IsInteger(obj Object) bool {
+	return obj.Type() == INTEGER_OBJ || obj.Type() == BIG_INTEGER_OBJ
+}
+
+// Int64Arithmetic applies +, -, *, / or % to two int64s. It reports false if
+// the result overflowed, in which case it has wrapped. The divisor must not
+// be zero.
+func Int64Arithmetic(operator string, left, right int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := left + right
+		return result, (result > left) == (right > 0)
+	case "-":
+		result := left - right
+		return result, (result < left) == (right > 0)
+	case "*":
+		result := left * right
+		if left == 0 || right == 0 {
+			return result, true
+		}
+		overflow := result/right != left || (left == -1 && right == math.MinInt64) ||
+			(right == -1 && left == math.MinInt64)
+		return result, !overflow
+	case "/":
+		return left / right, !(left == math.MinInt64 && right == -1)
+	default:
+		return left % right, true
+	}
+}
+
+// IntegerArithmetic applies +, -, *, / or % to two integers, promoting the
+// result to a BigInt when it does not fit in an int64. Division truncates
+// like it does for int64. The divisor must not be zero.
+func IntegerArithmetic(operator string, left, right Object) Object {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		if result, ok := Int64Arithmetic(operator, l.Value, r.Value); ok {
+			return &Integer{Value: result}
+		}
+	}
+
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(toBig(left), toBig(right))
+	case "-":
+		result.Sub(toBig(left), toBig(right))
+	case "*":
+		result.Mul(toBig(left), toBig(right))
+	case "/":
+		result.Quo(toBig(left), toBig(right))
+	default:
+		result.Rem(toBig(left), toBig(right))
+	}
+
+	return NewInteger(result)
+}
+
+// Negate returns -obj for an integer, promoting -math.MinInt64.
+func Negate(obj Object) Object {
+	if i, ok := obj.(*Integer); ok && i.Value != math.MinInt64 {
+		return &Integer{Value: -i.Value}
+	}
+
+	return NewInteger(new(big.Int).Neg(toBig(obj)))
+}
+
+// CompareIntegers returns -1, 0 or +1 as left is less than, equal to or
+// greater than right.
+func CompareIntegers(left, right Object) int {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		switch {
+		case l.Value < r.Value:
+			return -1
+		case l.Value > r.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	return toBig(left).Cmp(toBig(right))
+}
+
+// IsZero reports whether the integer obj is zero.
+func IsZero(obj Object) bool {
+	i, ok := obj.(*Integer)
+	return ok && i.Value == 0
+}
+
+// BigToFloat converts a BigInt to the nearest float64.
+func BigToFloat(b *BigInt) float64 {
+	f, _ := new(big.Float).SetInt(b.Value).Float64()
+	return f
+}
+
+func toBig(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	default:
+		return new(big.Int)
+	}
+}
@@ -0,0 +1,30 @@
+package object
+
+import "errors"
+
+// ErrStepBudget and ErrAllocationBudget are why a program was stopped for
+// running out of a budget, by either engine.
+var (
+	ErrStepBudget       = errors.New("step budget exceeded")
+	ErrAllocationBudget = errors.New("allocation budget exceeded")
+)
+
+// Allocation is how much obj counts towards an allocation budget: one per
+// value and one per element, pair or byte of arrays, hashes and strings and
+// per machine word of big integers. Booleans, null and errors are free.
+func Allocation(obj Object) int {
+	switch obj := obj.(type) {
+	case *Array:
+		return 1 + len(obj.Elements)
+	case *Hash:
+		return 1 + len(obj.Pairs)
+	case *String:
+		return 1 + len(obj.Value)
+	case *BigInt:
+		return 1 + len(obj.Value.Bits())
+	case nil, *Boolean, *Null, *Error:
+		return 0
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,346 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"längd",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return &Integer{Value: int64(len(arg.Elements))}
+			case *String:
+				return &Integer{Value: int64(len(arg.Value))}
+			default:
+				return newError("argument to `längd` not supported, got=%s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"första",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `första` must be ARRAY, got=%s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"sista",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `första` must be ARRAY, got=%s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"resterande",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `första` must be ARRAY, got=%s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"läggtill",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != ARRAY_OBJ {
+				return newError("argument to `första` must be ARRAY, got=%s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+
+			newElements := make([]Object, length+1, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &Array{Elements: newElements}
+		},
+		},
+	},
+	{
+		"skriv",
+		&Builtin{Fn: func(args ...Object) Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+
+			return nil
+		},
+		},
+	},
+	{
+		"avrunda",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			if !isNumber(args[0]) {
+				return newError("argument to `avrunda` not supported, got=%s", args[0].Type())
+			}
+
+			if len(args) == 1 {
+				if IsInteger(args[0]) {
+					return args[0]
+				}
+
+				return floatToInteger("avrunda", math.Round(toFloat(args[0])))
+			}
+
+			decimals, ok := args[1].(*Integer)
+			if !ok {
+				return newError("second argument to `avrunda` must be INTEGER, got=%s", args[1].Type())
+			}
+
+			if IsInteger(args[0]) {
+				return roundInteger(args[0], decimals.Value)
+			}
+
+			scale := math.Pow(10, float64(decimals.Value))
+
+			return &Float{Value: math.Round(toFloat(args[0])*scale) / scale}
+		},
+		},
+	},
+	{
+		"golv",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if !isNumber(args[0]) {
+				return newError("argument to `golv` not supported, got=%s", args[0].Type())
+			}
+
+			if IsInteger(args[0]) {
+				return args[0]
+			}
+
+			return floatToInteger("golv", math.Floor(toFloat(args[0])))
+		},
+		},
+	},
+	{
+		"tak",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if !isNumber(args[0]) {
+				return newError("argument to `tak` not supported, got=%s", args[0].Type())
+			}
+
+			if IsInteger(args[0]) {
+				return args[0]
+			}
+
+			return floatToInteger("tak", math.Ceil(toFloat(args[0])))
+		},
+		},
+	},
+	{
+		"heltal",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				return arg
+			case *Float:
+				return floatToInteger("heltal", math.Trunc(arg.Value))
+			case *String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError("could not parse %q as integer", arg.Value)
+				}
+				return NewInteger(value)
+			default:
+				return newError("argument to `heltal` not supported, got=%s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"flyttal",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer:
+				return &Float{Value: float64(arg.Value)}
+			case *BigInt:
+				return &Float{Value: BigToFloat(arg)}
+			case *Float:
+				return arg
+			case *String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("could not parse %q as float", arg.Value)
+				}
+				return &Float{Value: value}
+			default:
+				return newError("argument to `flyttal` not supported, got=%s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"fel",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			message, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `fel` must be STRING, got=%s", args[0].Type())
+			}
+
+			kind := ERROR_KIND
+			if len(args) == 2 {
+				arg, ok := args[1].(*String)
+				if !ok {
+					return newError("argument to `fel` must be STRING, got=%s", args[1].Type())
+				}
+				kind = arg.Value
+			}
+
+			return &ErrorValue{Error: &Error{Message: message.Value, Kind: kind}}
+		},
+		},
+	},
+}
+
+// floatToInteger converts an already rounded float, failing for values that
+// do not fit in an INTEGER.
+func floatToInteger(name string, value float64) Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return newError("result of `%s` out of range for INTEGER: %g", name, value)
+	}
+
+	if value < math.MinInt64 || value >= math.MaxInt64 {
+		result, _ := big.NewFloat(value).Int(nil)
+		return NewInteger(result)
+	}
+
+	return &Integer{Value: int64(value)}
+}
+
+// roundInteger rounds an integer to decimals decimals, half away from zero
+// as math.Round. Only negative decimals change it, avrunda(1250, -2) is 1300.
+func roundInteger(obj Object, decimals int64) Object {
+	if decimals >= 0 {
+		return obj
+	}
+
+	value := toBig(obj)
+	if -decimals > int64(len(value.String())) {
+		return &Integer{Value: 0}
+	}
+
+	unit := new(big.Int).Exp(big.NewInt(10), big.NewInt(-decimals), nil)
+	quotient, remainder := new(big.Int).QuoRem(value, unit, new(big.Int))
+
+	if new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(unit) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	}
+
+	return NewInteger(quotient.Mul(quotient, unit))
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+
+	return nil
+}
+
+// newError makes the error of a builtin, these are all about its arguments.
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: ARGUMENT_ERROR_KIND}
+}
+
+func isNumber(obj Object) bool {
+	return IsInteger(obj) || obj.Type() == FLOAT_OBJ
+}
+
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInt:
+		return BigToFloat(obj)
+	case *Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
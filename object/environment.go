@@ -1,8 +1,12 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	// passThrough makes Set bind names it does not hold in outer
+	passThrough bool
 }
 
 func NewEnvironment() *Environment {
@@ -16,6 +20,16 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
+// NewBlockEnvironment returns an environment for a block with names of its
+// own, bound with Bind, such as the loop variables of one iteration of a loop
+// or the parameter of a fånga. Names bound with Set are bound in outer, as
+// låt is in the body of any block.
+func NewBlockEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.passThrough = true
+	return env
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
@@ -26,6 +40,44 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if _, ok := e.store[name]; !ok && e.passThrough {
+		return e.outer.Set(name, val)
+	}
+
 	e.store[name] = val
 	return val
 }
+
+// Bind binds name in this scope, even in a block environment.
+func (e *Environment) Bind(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign rebinds an existing name in the scope where it was declared, which
+// may be an outer one. It reports false if the name is not bound anywhere.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return nil, false
+}
+
+// Names returns the names bound in this scope, not those of outer scopes,
+// in sorted order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/code"
+	"github.com/oliversabler/apa/token"
 )
 
 type ObjectType string
@@ -15,17 +20,37 @@ type BuiltinFunction func(args ...Object) Object
 
 const (
 	ARRAY_OBJ        = "ARRAY"
+	BIG_INTEGER_OBJ  = "BIG_INTEGER"
 	BOOLEAN_OBJ      = "BOOLEAN"
+	BREAK_OBJ        = "BREAK"
 	BUILTIN_OBJ      = "BUILTIN"
+	CELL_OBJ         = "CELL"
+	COMPILED_FN_OBJ  = "COMPILED_FUNCTION"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
+	FLOAT_OBJ        = "FLOAT"
 	FUNCTION_OBJ     = "FUNCTION"
 	HASH_OBJ         = "HASH"
 	INTEGER_OBJ      = "INTEGER"
+	MODULE_OBJ       = "MODULE"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN"
 	STRING_OBJ       = "STRING"
 )
 
+// Kinds of errors, given by the typ field of an error caught with fånga.
+const (
+	ERROR_KIND            = "fel"
+	ARGUMENT_ERROR_KIND   = "argumentfel"
+	ARITHMETIC_ERROR_KIND = "aritmetikfel"
+	IMPORT_ERROR_KIND     = "importfel"
+	INDEX_ERROR_KIND      = "indexfel"
+	NAME_ERROR_KIND       = "namnfel"
+	RECURSION_ERROR_KIND  = "rekursionsfel"
+	TYPE_ERROR_KIND       = "typfel"
+)
+
 type Hashable interface {
 	HashKey() HashKey
 }
@@ -40,11 +65,24 @@ type Array struct {
 }
 
 func (a *Array) Inspect() string {
+	return a.inspect(map[Object]bool{})
+}
+
+// inspect is Inspect, printing [...] for an array that is already being
+// printed in seen, so that an array that contains itself can be printed.
+func (a *Array) inspect(seen map[Object]bool) string {
+	if seen[a] {
+		return "[...]"
+	}
+
+	seen[a] = true
+	defer delete(seen, a)
+
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, el := range a.Elements {
-		elements = append(elements, el.Inspect())
+		elements = append(elements, inspect(el, seen))
 	}
 
 	out.WriteString("[")
@@ -54,6 +92,19 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// inspect is the Inspect of an element of an array or hash, which passes on
+// the arrays and hashes being printed.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(seen)
+	case *Hash:
+		return obj.inspect(seen)
+	default:
+		return obj.Inspect()
+	}
+}
+
 func (a *Array) Type() ObjectType {
 	return ARRAY_OBJ
 }
@@ -70,6 +121,16 @@ func (b *Boolean) Type() ObjectType {
 	return BOOLEAN_OBJ
 }
 
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return "bryt"
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
 }
@@ -82,8 +143,126 @@ func (b *Builtin) Type() ObjectType {
 	return BUILTIN_OBJ
 }
 
+// Cell boxes a variable that is captured by a closure in the VM, so that the
+// closure and the enclosing function share one binding.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell()"
+	}
+
+	return "cell(" + c.Value.Inspect() + ")"
+}
+
+func (c *Cell) Type() ObjectType {
+	return CELL_OBJ
+}
+
+// Closure is a compiled function together with the cells of the variables it
+// captured. It has the same type as Function so both engines report it alike.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string
+	FreeNames     []string
+	Name          string
+	Positions     []SourcePosition // In order of offset
+}
+
+// SourcePosition marks the instructions from Offset up to the next
+// SourcePosition as compiled from the code at Pos.
+type SourcePosition struct {
+	Offset int
+	Pos    token.Position
+}
+
+// PositionAt returns the position of the code the instruction at ip was
+// compiled from, or the zero Position if it is not known.
+func (cf *CompiledFunction) PositionAt(ip int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool { return cf.Positions[i].Offset > ip })
+	if i == 0 {
+		return token.Position{}
+	}
+
+	return cf.Positions[i-1].Pos
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FN_OBJ
+}
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return "fortsätt"
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
 type Error struct {
 	Message string
+	Kind    string         // One of the error kinds, ERROR_KIND if empty
+	Pos     token.Position // Where the error was raised, if known
+	Stack   []StackFrame   // The calls it propagated out of, innermost first
+}
+
+// StackFrame is a call of a function that an error propagated out of.
+type StackFrame struct {
+	Function string
+	Call     token.Position
+}
+
+// Trace formats the error followed by where it was raised and the calls it
+// propagated out of, one per line. A run of identical calls, as left by a
+// runaway recursion, is written once followed by how many times it repeats.
+func (e *Error) Trace() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Inspect())
+	out.WriteString("\n")
+
+	if e.Pos.IsValid() {
+		out.WriteString("\tat " + e.Pos.String() + "\n")
+	}
+
+	for i := 0; i < len(e.Stack); {
+		frame := e.Stack[i]
+		out.WriteString("\tin " + frame.Function + " called at " + frame.Call.String() + "\n")
+
+		repeats := 0
+		for i++; i < len(e.Stack) && e.Stack[i] == frame; i++ {
+			repeats++
+		}
+
+		if repeats > 0 {
+			fmt.Fprintf(&out, "\t... repeated %d more times\n", repeats)
+		}
+	}
+
+	return out.String()
 }
 
 func (e *Error) Inspect() string {
@@ -94,7 +273,62 @@ func (e *Error) Type() ObjectType {
 	return ERROR_OBJ
 }
 
+// ErrorKind returns the kind of the error, defaulting to ERROR_KIND.
+func (e *Error) ErrorKind() string {
+	if e.Kind == "" {
+		return ERROR_KIND
+	}
+
+	return e.Kind
+}
+
+// ErrorValue is an error as a value, caught by fånga or made by fel. Unlike
+// an Error it does not stop the evaluation until it is thrown with kasta.
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Inspect() string {
+	return fmt.Sprintf("fel(%q, %q)", ev.Error.Message, ev.Error.ErrorKind())
+}
+
+func (ev *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE_OBJ
+}
+
+// Field returns the meddelande or typ field of the error.
+func (ev *ErrorValue) Field(name string) (Object, bool) {
+	switch name {
+	case "meddelande":
+		return &String{Value: ev.Error.Message}, true
+	case "typ":
+		return &String{Value: ev.Error.ErrorKind()}, true
+	default:
+		return nil, false
+	}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Inspect() string {
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+
+	// Keep whole floats recognisable as floats, 2.0 rather than 2
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+
+	return str
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
 type Function struct {
+	Name       string
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -127,11 +361,24 @@ type Hash struct {
 }
 
 func (h *Hash) Inspect() string {
+	return h.inspect(map[Object]bool{})
+}
+
+// inspect is Inspect, printing {...} for a hash that is already being
+// printed in seen.
+func (h *Hash) inspect(seen map[Object]bool) string {
+	if seen[h] {
+		return "{...}"
+	}
+
+	seen[h] = true
+	defer delete(seen, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
 	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, seen)))
 	}
 
 	out.WriteString("{")
@@ -143,6 +390,42 @@ func (h *Hash) Inspect() string {
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
+// SortedPairs returns the pairs ordered by key, so that iteration does not
+// depend on Go's random map order.
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return hashKeyLess(pairs[i].Key, pairs[j].Key)
+	})
+
+	return pairs
+}
+
+func hashKeyLess(a, b Object) bool {
+	if IsInteger(a) && IsInteger(b) {
+		return CompareIntegers(a, b) < 0
+	}
+
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	default:
+		return a.Inspect() < b.Inspect()
+	}
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -160,6 +443,10 @@ func (b *Boolean) HashKey() HashKey {
 	return HashKey{Type: b.Type(), Value: value}
 }
 
+func (f *Float) HashKey() HashKey {
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
@@ -188,6 +475,34 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+// Module is a file loaded with importera. The names it binds with låt at the
+// top level are its exports, read with modul.namn. They are read from the
+// environment of the module, so an export reassigned by the module, such as
+// by one of its functions, reads as its new value.
+type Module struct {
+	Name    string
+	Path    string
+	Env     *Environment
+	Exports map[string]bool
+}
+
+// Get returns the value of the export name.
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+
+	return m.Env.Get(name)
+}
+
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("modul %q", m.Path)
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
 type Null struct {
 }
 
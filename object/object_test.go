@@ -1,6 +1,11 @@
 package object
 
-import "testing"
+import (
+	"math/big"
+	"testing"
+
+	"github.com/oliversabler/apa/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hej1 := &String{Value: "Hej Världen"}
@@ -20,3 +25,151 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have the same hash keys")
 	}
 }
+
+func TestBigIntHashKey(t *testing.T) {
+	big1 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}
+	big2 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}
+	negative := &BigInt{Value: new(big.Int).Neg(big1.Value)}
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with the same value have different hash keys")
+	}
+
+	if big1.HashKey() == negative.HashKey() {
+		t.Errorf("big integers with different signs have the same hash keys")
+	}
+}
+
+func TestNewIntegerDemotes(t *testing.T) {
+	if _, ok := NewInteger(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("NewInteger did not demote a value that fits in an int64")
+	}
+
+	if _, ok := NewInteger(new(big.Int).Lsh(big.NewInt(1), 63)).(*BigInt); !ok {
+		t.Errorf("NewInteger did not keep a value that overflows an int64")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1.5, "1.5"},
+		{2, "2.0"},
+		{-3, "-3.0"},
+		{1e21, "1e+21"},
+		{0.0001, "0.0001"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Inspect() wrong. expected=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	err := &Error{
+		Message: "identifier not found: x",
+		Pos:     token.Position{Line: 2, Column: 5},
+		Stack: []StackFrame{
+			{Function: "inre", Call: token.Position{Line: 4, Column: 3}},
+			{Function: "yttre", Call: token.Position{Filename: "apa.apa", Line: 6, Column: 1}},
+		},
+	}
+
+	expected := `ERROR: identifier not found: x
+	at 2:5
+	in inre called at 4:3
+	in yttre called at apa.apa:6:1
+`
+
+	if err.Trace() != expected {
+		t.Errorf("Trace() wrong. expected=%q, got=%q", expected, err.Trace())
+	}
+
+	recursion := &Error{Message: "maximalt rekursionsdjup överskridet", Pos: token.Position{Line: 1, Column: 25}}
+	for i := 0; i < 1000; i++ {
+		recursion.Stack = append(recursion.Stack, StackFrame{Function: "f", Call: token.Position{Line: 1, Column: 25}})
+	}
+	recursion.Stack = append(recursion.Stack, StackFrame{Function: "f", Call: token.Position{Line: 2, Column: 1}})
+
+	expected = `ERROR: maximalt rekursionsdjup överskridet
+	at 1:25
+	in f called at 1:25
+	... repeated 999 more times
+	in f called at 2:1
+`
+
+	if recursion.Trace() != expected {
+		t.Errorf("Trace() wrong. expected=%q, got=%q", expected, recursion.Trace())
+	}
+
+	bare := &Error{Message: "oops"}
+	if bare.Trace() != "ERROR: oops\n" {
+		t.Errorf("Trace() wrong. expected=%q, got=%q", "ERROR: oops\n", bare.Trace())
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("y", &Integer{Value: 2})
+
+	if _, ok := inner.Assign("x", &Integer{Value: 10}); !ok {
+		t.Fatalf("Assign did not find x in outer environment")
+	}
+
+	if _, ok := inner.store["x"]; ok {
+		t.Errorf("Assign created x in inner environment")
+	}
+
+	if x, _ := outer.Get("x"); x.(*Integer).Value != 10 {
+		t.Errorf("x in outer environment not updated. got=%d", x.(*Integer).Value)
+	}
+
+	if _, ok := inner.Assign("z", &Integer{Value: 3}); ok {
+		t.Errorf("Assign succeeded for undeclared z")
+	}
+
+	if _, ok := inner.Get("z"); ok {
+		t.Errorf("Assign bound undeclared z")
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 0}}}
+	array.Elements[0] = array
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "själv"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: hash}
+
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+	twice := &Array{Elements: []Object{shared, shared}}
+
+	nested := &Array{}
+	inner := &Hash{Pairs: map[HashKey]HashPair{}}
+	inner.Pairs[key.HashKey()] = HashPair{Key: key, Value: nested}
+	nested.Elements = []Object{inner}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[[...]]"},
+		{hash, "{själv: {...}}"},
+		{twice, "[[1], [1]]"},
+		{nested, "[{själv: [...]}]"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
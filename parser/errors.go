@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/oliversabler/apa/token"
+)
+
+// ErrUnexpectedEOF is the Err of a ParseError for input that ends in the
+// middle of a statement, a string or a comment, which more input could
+// complete.
+var ErrUnexpectedEOF = errors.New("unexpected end of input")
+
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.TokenType
+	Found    token.Token
+	Message  string
+	// Err is ErrUnexpectedEOF if the error was found at the end of the
+	// input, nil otherwise
+	Err error
+}
+
+func (pe *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", pe.Pos, pe.Message)
+}
+
+func (pe *ParseError) Unwrap() error {
+	return pe.Err
+}
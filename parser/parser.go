@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/oliversabler/apa/ast"
 	"github.com/oliversabler/apa/lexer"
@@ -12,6 +16,9 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	OR
+	AND
 	EQUALS
 	LESSGREATER
 	SUM
@@ -22,16 +29,28 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQUAL:    EQUALS,
-	token.NOTEQUAL: EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQUAL:           EQUALS,
+	token.NOTEQUAL:        EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LTE:             LESSGREATER,
+	token.GTE:             LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 type (
@@ -42,7 +61,8 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	errors    []*ParseError
+	panicking bool
 
 	curToken  token.Token
 	peekToken token.Token
@@ -54,18 +74,20 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -80,8 +102,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOTEQUAL, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -89,7 +123,7 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
@@ -99,6 +133,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		statement := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+
+			// A closing brace at the top level has no block to end, skip it
+			if p.curTokenIs(token.RBRACE) {
+				p.nextToken()
+			}
+
+			continue
+		}
+
 		if statement != nil {
 			program.Statements = append(program.Statements, statement)
 		}
@@ -124,6 +169,18 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -137,12 +194,21 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		statement := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
+
 		if statement != nil {
 			block.Statements = append(block.Statements, statement)
 		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.RBRACE) {
+		block.Close = p.curToken
+	}
+
 	return block
 }
 
@@ -163,6 +229,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	statement.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := statement.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = statement.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -184,6 +254,149 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return statement
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	statement := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	statement.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	statement := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	statement.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		statement.Key = statement.Value
+		statement.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	// i is not reserved, it is only a keyword in this position
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "i" {
+		message := fmt.Sprintf("expected next token to be i, got=%s", p.peekToken.Literal)
+		p.addError(p.peekToken, []token.TokenType{token.IDENT}, message)
+		return nil
+	}
+	p.nextToken()
+
+	p.nextToken()
+	statement.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	statement.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	statement := &ast.BreakStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	statement := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	statement := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	statement.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
+// parseImportStatement parses importera "fil.apa", which binds the module to
+// the file name without its extension, so that must be an identifier.
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	statement := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	statement.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	base := filepath.Base(statement.Path.Value)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if !isIdentifier(name) {
+		message := fmt.Sprintf("cannot name module %q, its file name is not an identifier", statement.Path.Value)
+		p.addError(p.curToken, nil, message)
+
+		return nil
+	}
+
+	nameToken := token.Token{Type: token.IDENT, Literal: name, Span: p.curToken.Span}
+	statement.Name = &ast.Identifier{Token: nameToken, Value: name}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	statement := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -237,6 +450,42 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if !p.expectPeek(token.CATCH) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Parameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Handler = p.parseBlockStatement()
+
+	return expression
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -257,6 +506,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Close = p.curToken
 
 	return array
 }
@@ -287,6 +537,8 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	hash.Close = p.curToken
+
 	return hash
 }
 
@@ -312,9 +564,32 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	literal := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			literal.Big = value
+			return literal
+		}
+	}
+
 	if err != nil {
 		message := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, message)
+		p.addError(p.curToken, nil, message)
+
+		return nil
+	}
+
+	literal.Value = value
+
+	return literal
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	literal := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		message := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, nil, message)
 
 		return nil
 	}
@@ -338,7 +613,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 
 	leftExpression := prefix()
 
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	for !p.panicking && !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExpression
@@ -394,6 +669,11 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 
+	// och/eller are spelled out aliases of &&/||
+	if p.curTokenIs(token.AND) || p.curTokenIs(token.OR) {
+		expression.Operator = string(p.curToken.Type)
+	}
+
 	precedence := p.curPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
@@ -401,6 +681,28 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		message := fmt.Sprintf("cannot assign to %s", target.String())
+		p.addError(p.curToken, nil, message)
+		return nil
+	}
+
+	// Assignment is right associative, a = b = 1 assigns b first
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	expression := &ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -412,12 +714,27 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	expression.Close = p.curToken
+
+	return expression
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	expression := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	expression.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
 	return expression
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: p.curToken, Function: function}
 	expression.Arguments = p.parseExpressionList(token.RPAREN)
+	expression.Close = p.curToken
 	return expression
 }
 
@@ -478,6 +795,24 @@ func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
 
+func (p *Parser) curTokenIsStatementKeyword() bool {
+	switch p.curToken.Type {
+	case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.THROW, token.IMPORT:
+		return true
+	default:
+		return false
+	}
+}
+
+// isIdentifier reports whether name lexes as a single identifier.
+func isIdentifier(name string) bool {
+	l := lexer.New(name)
+
+	tok := l.NextToken()
+
+	return tok.Type == token.IDENT && tok.Literal == name && l.NextToken().Type == token.EOF
+}
+
 func (p *Parser) peekTokenIs(t token.TokenType) bool {
 	return p.peekToken.Type == t
 }
@@ -493,13 +828,76 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	message := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, message)
+	var message string
+	switch t {
+	case token.ILLEGAL:
+		message = fmt.Sprintf("illegal character %q", p.curToken.Literal)
+	case token.ERROR, token.UNTERMINATED:
+		message = p.curToken.Literal
+	default:
+		message = fmt.Sprintf("no prefix parse function for %s found", t)
+	}
+
+	p.addError(p.curToken, nil, message)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
 	message := fmt.Sprintf("expected next token to be %s, got=%s", t, p.peekToken.Type)
-	p.errors = append(p.errors, message)
+	p.addError(p.peekToken, []token.TokenType{t}, message)
+}
+
+// addError records an error and puts the parser in panic mode. Until the
+// statement loop resynchronises, any further errors are assumed to be caused
+// by the first one and are dropped.
+func (p *Parser) addError(found token.Token, expected []token.TokenType, message string) {
+	if p.panicking {
+		return
+	}
+
+	var err error
+	if found.Type == token.EOF || found.Type == token.UNTERMINATED {
+		err = ErrUnexpectedEOF
+	}
+
+	p.panicking = true
+	p.errors = append(p.errors, &ParseError{
+		Pos:      found.Span.Start,
+		Expected: expected,
+		Found:    found,
+		Message:  message,
+		Err:      err,
+	})
+}
+
+// synchronize skips tokens until the parser is at a point where a new
+// statement can start: after a semicolon, at a statement keyword or at the
+// closing brace of the enclosing block. Nested blocks are skipped as a whole.
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := 0
+
+	for !p.curTokenIs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case token.SEMICOLON:
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		}
+
+		p.nextToken()
+
+		if depth == 0 && p.curTokenIsStatementKeyword() {
+			return
+		}
+	}
 }
 
 func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
@@ -1,11 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/oliversabler/apa/ast"
 	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/token"
 )
 
 func TestCallExpressionParsing(t *testing.T) {
@@ -226,6 +228,54 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a * [1,2,3,4][b * c] * d",
 			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
 		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"a och b eller !c",
+			"((a && b) || (!c))",
+		},
+		{
+			"x = y = 1 + 2",
+			"(x = (y = (1 + 2)))",
+		},
+		{
+			"x += a || b",
+			"(x += (a || b))",
+		},
+		{
+			"l[i + 1] *= 2",
+			"((l[(i + 1)]) *= 2)",
+		},
+		{
+			"-matte.pi * a.b.c(1)",
+			"((-(matte.pi)) * ((a.b).c)(1))",
+		},
+		{
+			"m.lista[0] + m.f(x).y",
+			"(((m.lista)[0]) + ((m.f)(x).y))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -505,6 +555,156 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestWhileStatement(t *testing.T) {
+	input := `medan (x < 10) { om (x == 5) { bryt; } fortsätt };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, statement.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(statement.Body.Statements) != 2 {
+		t.Fatalf("statement.Body.Statements does not contain 2 statements. got=%d",
+			len(statement.Body.Statements))
+	}
+
+	ifStatement := statement.Body.Statements[0].(*ast.ExpressionStatement)
+	ifExpression := ifStatement.Expression.(*ast.IfExpression)
+	if _, ok := ifExpression.Consequence.Statements[0].(*ast.BreakStatement); !ok {
+		t.Errorf("consequence is not ast.BreakStatement. got=%T",
+			ifExpression.Consequence.Statements[0])
+	}
+
+	if _, ok := statement.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("statement.Body.Statements[1] is not ast.ContinueStatement. got=%T",
+			statement.Body.Statements[1])
+	}
+}
+
+func TestLoopFollowedBySemicolon(t *testing.T) {
+	tests := []string{
+		`medan (x < 10) { x += 1 }; x`,
+		`för (x i l) { x }; x`,
+	}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("program.Statements for %q does not contain 2 statements. got=%d",
+				input, len(program.Statements))
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	input := `försök { kasta x; } fånga (fel) { fel }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	expression, ok := statement.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("statement.Expression is not ast.TryExpression. got=%T", statement.Expression)
+	}
+
+	throw, ok := expression.Body.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("body is not ast.ThrowStatement. got=%T", expression.Body.Statements[0])
+	}
+
+	if !testLiteralExpression(t, throw.Value, "x") {
+		return
+	}
+
+	if !testIdentifier(t, expression.Parameter, "fel") {
+		return
+	}
+
+	if len(expression.Handler.Statements) != 1 {
+		t.Errorf("handler does not contain 1 statement. got=%d", len(expression.Handler.Statements))
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedKey      string
+		expectedValue    string
+		expectedIterable string
+	}{
+		{"för (x i lista) { x }", "", "x", "lista"},
+		{"för (i, x i [1, 2]) { x }", "i", "x", "[1, 2]"},
+		{"för (i i intervall(10)) { i }", "", "i", "intervall(10)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		statement, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if tt.expectedKey == "" && statement.Key != nil {
+			t.Errorf("statement.Key is not nil. got=%s", statement.Key)
+		}
+
+		if tt.expectedKey != "" && !testIdentifier(t, statement.Key, tt.expectedKey) {
+			return
+		}
+
+		if !testIdentifier(t, statement.Value, tt.expectedValue) {
+			return
+		}
+
+		if statement.Iterable.String() != tt.expectedIterable {
+			t.Errorf("statement.Iterable wrong. expected=%q, got=%q",
+				tt.expectedIterable, statement.Iterable.String())
+		}
+
+		if len(statement.Body.Statements) != 1 {
+			t.Errorf("statement.Body.Statements does not contain 1 statement. got=%d",
+				len(statement.Body.Statements))
+		}
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -528,6 +728,53 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, statement.Expression, 5)
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "123456789012345678901234567890"
+
+	program := New(lexer.New(input)).ParseProgram()
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := statement.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", statement.Expression)
+	}
+
+	if literal.Big == nil || literal.Big.String() != input {
+		t.Errorf("literal.Big not %s. got=%v", input, literal.Big)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"0.25", 0.25},
+		{"2e3", 2000},
+		{"6.02E-2", 0.0602},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+
+		literal, ok := statement.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", statement.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestParsingIndexExpression(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -552,6 +799,66 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedPath string
+		expectedName string
+	}{
+		{`importera "matte.apa";`, "matte.apa", "matte"},
+		{`importera "../lib/sträng_verktyg.apa"`, "../lib/sträng_verktyg.apa", "sträng_verktyg"},
+		{`importera "hjälp"`, "hjälp", "hjälp"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		statement, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("statement not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if statement.Path.Value != tt.expectedPath {
+			t.Errorf("statement.Path.Value not %q. got=%q", tt.expectedPath, statement.Path.Value)
+		}
+
+		if statement.Name.Value != tt.expectedName {
+			t.Errorf("statement.Name.Value not %q. got=%q", tt.expectedName, statement.Name.Value)
+		}
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	input := "matte.kvadrat"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	statement := program.Statements[0].(*ast.ExpressionStatement)
+	member, ok := statement.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", statement.Expression)
+	}
+
+	if !testIdentifier(t, member.Object, "matte") {
+		return
+	}
+
+	if member.Member.Value != "kvadrat" {
+		t.Errorf("member.Member.Value not %q. got=%q", "kvadrat", member.Member.Value)
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hej världen";`
 
@@ -629,6 +936,29 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `låt minFunktion = funktion() { };`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	statement, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T", program.Statements[0])
+	}
+
+	function, ok := statement.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("statement.Value is not ast.FunctionLiteral. got=%T", statement.Value)
+	}
+
+	if function.Name != "minFunktion" {
+		t.Fatalf("function literal name wrong. want 'minFunktion', got=%q\n", function.Name)
+	}
+}
+
 // Todo fix to be like TestLetStatements
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
@@ -693,8 +1023,8 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has %d errors", len(errors))
-	for _, message := range errors {
-		t.Errorf("parser error: %q", message)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err.Error())
 		t.FailNow()
 	}
 }
@@ -838,3 +1168,228 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 
 	return true
 }
+
+func TestNodeSpans(t *testing.T) {
+	input := `låt x = 1 + 2;
+addera(x, [1, 2])[0];
+om (x) { x } annars { -x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d",
+			len(program.Statements))
+	}
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "3:27"},
+		{program.Statements[0], "1:1", "1:14"},
+		{program.Statements[0].(*ast.LetStatement).Value, "1:9", "1:14"},
+		{program.Statements[1], "2:1", "2:21"},
+		{program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.IndexExpression).Left, "2:1", "2:18"},
+		{program.Statements[2], "3:1", "3:27"},
+		{program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.IfExpression).Consequence, "3:8", "3:13"},
+	}
+
+	for i, tt := range tests {
+		span := tt.node.Span()
+
+		if span.Start.String() != tt.expectedStart {
+			t.Errorf("tests[%d] - start wrong for %q. expected=%s, got=%s",
+				i, tt.node.String(), tt.expectedStart, span.Start)
+		}
+
+		if span.End.String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong for %q. expected=%s, got=%s",
+				i, tt.node.String(), tt.expectedEnd, span.End)
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"låt = 5;",
+			[]string{"1:5: expected next token to be IDENT, got=="},
+		},
+		{
+			"addera(1, 2;",
+			[]string{"1:12: expected next token to be ), got=;"},
+		},
+		{
+			"låt x = @;",
+			[]string{`1:9: illegal character "@"`},
+		},
+		{
+			"1 + x = 5;",
+			[]string{"1:7: cannot assign to (1 + x)"},
+		},
+		{
+			"för (x av lista) { x }",
+			[]string{"1:8: expected next token to be i, got=av"},
+		},
+		{
+			`låt x = "ingen slut`,
+			[]string{"1:9: unterminated string"},
+		},
+		{
+			`importera "2d-grafik.apa";`,
+			[]string{`1:11: cannot name module "2d-grafik.apa", its file name is not an identifier`},
+		},
+		{
+			"importera matte;",
+			[]string{"1:11: expected next token to be STRING, got=IDENT"},
+		},
+		{
+			"matte.1",
+			[]string{"1:7: expected next token to be IDENT, got=INT"},
+		},
+		{
+			`låt x 5;
+låt y = (1 + 2;
+låt z = 3;
+om (z { låt a = 1; a }
+låt 9 = z;`,
+			[]string{
+				"1:7: expected next token to be =, got=INT",
+				"2:15: expected next token to be ), got=;",
+				"4:7: expected next token to be ), got={",
+				"5:5: expected next token to be IDENT, got=INT",
+			},
+		},
+		{
+			`låt f = funktion(x) {
+    låt = x;
+    x + ;
+};
+}
+f(1);`,
+			[]string{
+				"2:9: expected next token to be IDENT, got==",
+				"3:9: no prefix parse function for ; found",
+				"5:1: no prefix parse function for } found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			for _, err := range errors {
+				t.Logf("parser error: %s", err)
+			}
+			t.Fatalf("wrong number of errors for %q. expected=%d, got=%d",
+				tt.input, len(tt.expected), len(errors))
+		}
+
+		for i, err := range errors {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, tt.expected[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestParserRecoversStatements(t *testing.T) {
+	input := `låt a = 1;
+låt b = ;
+låt c = 3;
+låt d = funktion() { låt = 1; 2 };
+låt e = 5;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("expected 2 errors. got=%d", len(p.Errors()))
+	}
+
+	expected := []string{"a", "c", "d", "e"}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d",
+			len(expected), len(program.Statements))
+	}
+
+	for i, name := range expected {
+		if !testLetStatement(t, program.Statements[i], name) {
+			return
+		}
+	}
+
+	function := program.Statements[2].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if len(function.Body.Statements) != 1 {
+		t.Errorf("function.Body.Statements does not contain 1 statement. got=%d",
+			len(function.Body.Statements))
+	}
+}
+
+func TestParseErrorFields(t *testing.T) {
+	l := lexer.NewWithFilename("fel.apa", "om (sant {}")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 error. got=%d", len(p.Errors()))
+	}
+
+	err := p.Errors()[0]
+
+	if err.Pos.Filename != "fel.apa" || err.Pos.Line != 1 || err.Pos.Column != 10 {
+		t.Errorf("err.Pos wrong. got=%s", err.Pos)
+	}
+
+	if len(err.Expected) != 1 || err.Expected[0] != token.RPAREN {
+		t.Errorf("err.Expected wrong. got=%v", err.Expected)
+	}
+
+	if err.Found.Type != token.LBRACE {
+		t.Errorf("err.Found wrong. got=%s", err.Found.Type)
+	}
+
+	if err.Error() != "fel.apa:1:10: expected next token to be ), got={" {
+		t.Errorf("err.Error() wrong. got=%q", err.Error())
+	}
+}
+
+func TestUnexpectedEOF(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 +", true},
+		{"om (sant", true},
+		{`låt s = "öppen`, true},
+		{"/* öppen", true},
+		{"1 + )", false},
+		{`"\q"`, false},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected errors for %q", tt.input)
+			continue
+		}
+
+		if got := errors.Is(p.Errors()[0], ErrUnexpectedEOF); got != tt.expected {
+			t.Errorf("wrong errors.Is(err, ErrUnexpectedEOF) for %q. want=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package repl
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+	"github.com/oliversabler/apa/token"
+)
+
+// commands are the meta-commands of the REPL, entered as :name argument.
+var commands = map[string]func(s *session, arg string){
+	"load":   (*session).load,
+	"env":    (*session).env,
+	"reset":  (*session).reset,
+	"ast":    (*session).ast,
+	"tokens": (*session).tokens,
+	"type":   (*session).typeOf,
+}
+
+const commandHelp = ":load file, :env, :reset, :ast code, :tokens code, :type code"
+
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	arg = strings.TrimSpace(arg)
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, the commands are %s\n", name, commandHelp)
+		return
+	}
+
+	cmd(s, arg)
+}
+
+// parse parses src, printing its errors if it has any.
+func (s *session) parse(filename, src string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewWithFilename(filename, src))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+
+	return program, true
+}
+
+// load runs a file in the session, so that its bindings can be used.
+func (s *session) load(filename string) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	if program, ok := s.parse(filename, string(src)); ok {
+		s.run(program)
+	}
+}
+
+// env lists the bindings of the session.
+func (s *session) env(string) {
+	bindings := s.engine.bindings()
+
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%s = %s\n", name, bindings[name].Inspect())
+	}
+}
+
+// reset starts the session over without any bindings.
+func (s *session) reset(string) {
+	s.engine = s.newEngine()
+}
+
+// tokens lists the tokens the lexer reads src as.
+func (s *session) tokens(src string) {
+	l := lexer.New(src)
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s\t%s\t%q\n", tok.Span.Start, tok.Type, tok.Literal)
+	}
+}
+
+// typeOf evaluates src in the session and shows the type of its value.
+func (s *session) typeOf(src string) {
+	program, ok := s.parse("", src)
+	if !ok {
+		return
+	}
+
+	evaluated := s.engine.run(program)
+	switch evaluated := evaluated.(type) {
+	case *object.Error:
+		fmt.Fprint(s.out, evaluated.Trace())
+	case nil:
+		fmt.Fprintln(s.out, object.NULL_OBJ)
+	default:
+		fmt.Fprintln(s.out, evaluated.Type())
+	}
+}
+
+// ast shows the syntax tree the parser reads src as.
+func (s *session) ast(src string) {
+	if program, ok := s.parse("", src); ok {
+		dumpNode(s, "", reflect.ValueOf(program), 0)
+	}
+}
+
+var (
+	nodeType  = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(token.Token{})
+)
+
+// dumpNode prints a node of the syntax tree as its type and the values of
+// its fields, followed by its children indented one step further, each
+// labelled with the field it is in.
+func dumpNode(s *session, label string, v reflect.Value, depth int) {
+	if v.IsNil() {
+		return
+	}
+
+	node := v.Elem()
+	if node.Kind() == reflect.Pointer {
+		node = node.Elem()
+	}
+
+	line := strings.Repeat("  ", depth) + label + node.Type().Name()
+
+	type child struct {
+		label string
+		value reflect.Value
+	}
+	var children []child
+
+	for i := 0; i < node.NumField(); i++ {
+		field, value := node.Type().Field(i), node.Field(i)
+
+		switch {
+		case field.Type == tokenType:
+		case field.Type.Implements(nodeType):
+			children = append(children, child{field.Name + ": ", value})
+		case field.Type.Kind() == reflect.Slice:
+			for j := 0; j < value.Len(); j++ {
+				children = append(children, child{fmt.Sprintf("%s[%d]: ", field.Name, j), value.Index(j)})
+			}
+		case field.Type.Kind() == reflect.Map:
+			for _, key := range sortedKeys(value) {
+				children = append(children, child{"Key: ", key}, child{"Value: ", value.MapIndex(key)})
+			}
+		case field.Type == reflect.TypeOf((*big.Int)(nil)):
+			if !value.IsNil() {
+				line += fmt.Sprintf(" %s=%s", field.Name, value.Interface())
+			}
+		case value.IsZero() && field.Type.Kind() == reflect.String:
+		default:
+			line += fmt.Sprintf(" %s=%#v", field.Name, value.Interface())
+		}
+	}
+
+	fmt.Fprintln(s.out, line)
+
+	for _, c := range children {
+		dumpNode(s, c.label, c.value, depth+1)
+	}
+}
+
+// sortedKeys returns the keys of a map of nodes in source order.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Interface().(ast.Node).Span().Start.Offset <
+			keys[j].Interface().(ast.Node).Span().Start.Offset
+	})
+
+	return keys
+}
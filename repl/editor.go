@@ -0,0 +1,428 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// errInterrupted is returned by readLine when the line is abandoned with
+// Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the lines of input to the REPL, showing prompt first.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// newLineReader returns a line editor if in and out are a terminal, and a
+// plain reader of lines otherwise.
+func newLineReader(in io.Reader, out io.Writer, complete func(word string) []string) lineReader {
+	inFile, ok := in.(*os.File)
+	if !ok || !term.IsTerminal(int(inFile.Fd())) {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	if outFile, ok := out.(*os.File); !ok || !term.IsTerminal(int(outFile.Fd())) {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	}
+
+	fd := int(inFile.Fd())
+
+	history, err := loadHistory(historyPath())
+	if err != nil {
+		fmt.Fprintf(out, "history not saved: %s\n", err)
+	}
+
+	return &editor{
+		in:       bufio.NewReader(in),
+		out:      out,
+		history:  history,
+		complete: complete,
+		raw: func() (func(), error) {
+			state, err := term.MakeRaw(fd)
+			if err != nil {
+				return nil, err
+			}
+			return func() { term.Restore(fd, state) }, nil
+		},
+	}
+}
+
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) readLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+
+	return r.scanner.Text(), nil
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// editor is a line editor for terminals. It moves within the line and
+// through the history with the arrow keys and the Emacs control keys,
+// searches the history backwards with Ctrl-R and completes words with Tab.
+// Lines are assumed to fit on one row of the terminal.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  *history
+	complete func(word string) []string
+	// raw puts the terminal in raw mode while a line is read, it returns a
+	// func that restores it.
+	raw func() (func(), error)
+
+	prompt string
+	line   []rune
+	pos    int
+
+	historyPos int    // entry shown, len(history.entries) for the new line
+	draft      string // the new line, kept while going through the history
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt = prompt
+	e.line = nil
+	e.pos = 0
+	e.historyPos = len(e.history.entries)
+	e.refresh()
+
+	for {
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		if key == keyCtrlR {
+			if key, err = e.search(); err != nil {
+				return "", err
+			}
+		}
+
+		switch key {
+		case keyEnter, keyLineFeed:
+			io.WriteString(e.out, "\r\n")
+			line := string(e.line)
+			if err := e.history.add(line); err != nil {
+				fmt.Fprintf(e.out, "history not saved: %s\r\n", err)
+			}
+			return line, nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlB:
+			e.pos = max(e.pos-1, 0)
+		case keyCtrlF:
+			e.pos = min(e.pos+1, len(e.line))
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line = e.line[e.pos:]
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.line[start-1] != ' ' {
+				start--
+			}
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case keyCtrlP:
+			e.moveInHistory(-1)
+		case keyCtrlN:
+			e.moveInHistory(1)
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyTab:
+			e.completeWord()
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+				e.pos--
+			}
+		case keyEscape:
+			if err := e.escape(); err != nil {
+				return "", err
+			}
+		case keyCtrlG, 0:
+		default:
+			if unicode.IsPrint(key) {
+				e.insert([]rune{key})
+			}
+		}
+
+		e.refresh()
+	}
+}
+
+// escape handles an escape sequence, as sent by the arrow, Home, End and
+// Delete keys.
+func (e *editor) escape() error {
+	next, _, err := e.in.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return err
+	}
+
+	var sequence []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return err
+		}
+		sequence = append(sequence, r)
+		if r >= 0x40 && r <= 0x7e {
+			break
+		}
+	}
+
+	switch string(sequence) {
+	case "A":
+		e.moveInHistory(-1)
+	case "B":
+		e.moveInHistory(1)
+	case "C":
+		e.pos = min(e.pos+1, len(e.line))
+	case "D":
+		e.pos = max(e.pos-1, 0)
+	case "H", "1~", "7~":
+		e.pos = 0
+	case "F", "4~", "8~":
+		e.pos = len(e.line)
+	case "3~":
+		e.deleteForward()
+	}
+
+	return nil
+}
+
+func (e *editor) insert(runes []rune) {
+	line := make([]rune, 0, len(e.line)+len(runes))
+	line = append(line, e.line[:e.pos]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.pos:]...)
+	e.pos += len(runes)
+}
+
+func (e *editor) deleteForward() {
+	if e.pos < len(e.line) {
+		e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+	}
+}
+
+func (e *editor) setLine(line string) {
+	e.line = []rune(line)
+	e.pos = len(e.line)
+}
+
+// moveInHistory shows the entry delta steps away from the one shown.
+func (e *editor) moveInHistory(delta int) {
+	entries := e.history.entries
+
+	pos := e.historyPos + delta
+	if pos < 0 || pos > len(entries) {
+		return
+	}
+
+	if e.historyPos == len(entries) {
+		e.draft = string(e.line)
+	}
+
+	e.historyPos = pos
+	if pos == len(entries) {
+		e.setLine(e.draft)
+	} else {
+		e.setLine(entries[pos])
+	}
+}
+
+// search searches the history backwards for the query typed, Ctrl-R going
+// on to older matches. Any other key ends the search, leaving the match as
+// the line, and is returned to be handled as usual. Ctrl-G and Ctrl-C end
+// it leaving the line as it was.
+func (e *editor) search() (rune, error) {
+	original, originalPos := e.line, e.pos
+
+	var query []rune
+	match := len(e.history.entries)
+	failed := false
+
+	for {
+		text := ""
+		if match < len(e.history.entries) {
+			text = e.history.entries[match]
+		}
+
+		label := "reverse-i-search"
+		if failed {
+			label = "failing " + label
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), text)
+
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+
+		from := match
+		switch {
+		case key == keyCtrlR:
+			from = match - 1
+		case key == keyBackspace || key == keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			from = len(e.history.entries) - 1
+		case key == keyCtrlG || key == keyCtrlC:
+			e.line, e.pos = original, originalPos
+			return 0, nil
+		case unicode.IsPrint(key):
+			query = append(query, key)
+		default:
+			if text != "" {
+				e.setLine(text)
+			}
+			return key, nil
+		}
+
+		if found := e.history.search(string(query), from); found >= 0 {
+			match, failed = found, false
+		} else {
+			failed = true
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A single candidate is
+// filled in, several are filled in as far as they agree and are listed if
+// that does not add anything.
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.pos
+	for start > 0 && isIdentifierRune(e.line[start-1]) {
+		start--
+	}
+	if start == 1 && e.line[0] == ':' {
+		start = 0
+	}
+
+	word := string(e.line[start:e.pos])
+	candidates := e.complete(word)
+
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		e.insert([]rune(strings.TrimPrefix(candidates[0], word)))
+		return
+	}
+
+	prefix := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+
+	if len(prefix) > len(word) {
+		e.insert([]rune(strings.TrimPrefix(prefix, word)))
+		return
+	}
+
+	io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+}
+
+// refresh redraws the line and puts the cursor in its place.
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.prompt, string(e.line))
+
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func isIdentifierRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// completions returns the candidates starting with word among the names
+// given, sorted and without duplicates.
+func completions(word string, names ...[]string) []string {
+	seen := map[string]bool{}
+	var candidates []string
+
+	for _, list := range names {
+		for _, name := range list {
+			if strings.HasPrefix(name, word) && !seen[name] {
+				seen[name] = true
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	sort.Strings(candidates)
+
+	return candidates
+}
@@ -0,0 +1,217 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/oliversabler/apa/object"
+)
+
+func newTestEditor(input string, entries ...string) *editor {
+	return &editor{
+		in:      bufio.NewReader(strings.NewReader(input)),
+		out:     io.Discard,
+		history: &history{entries: entries},
+		complete: func(word string) []string {
+			return completions(word, []string{"längd", "längden", "låt", "lägg_till", "medan"})
+		},
+	}
+}
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		history  []string
+		expected string
+	}{
+		{"plain", "låt a = 1\r", nil, "låt a = 1"},
+		{"backspace", "abcd\x7f\x7fx\r", nil, "abx"},
+		{"left and insert", "ac\x1b[Db\r", nil, "abc"},
+		{"home and end", "bc\x1b[Ha\x1b[Fd\r", nil, "abcd"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", nil, "abcd"},
+		{"delete", "abc\x01\x1b[3~\r", nil, "bc"},
+		{"kill to end", "abc\x02\x02\x0b\r", nil, "a"},
+		{"kill to start", "abc\x02\x15\r", nil, "c"},
+		{"delete word", "låt apa\x17\r", nil, "låt "},
+		{"history up", "\x1b[A\x1b[A\r", []string{"ett", "två"}, "ett"},
+		{"history up and down", "ny\x1b[A\x1b[B\r", []string{"ett"}, "ny"},
+		{"reverse search", "\x12et\r", []string{"ett", "två", "tre"}, "ett"},
+		{"reverse search again", "\x12t\x12\r", []string{"ett", "två", "tre"}, "två"},
+		{"reverse search then edit", "\x12tv\x05!\r", []string{"ett", "två"}, "två!"},
+		{"reverse search cancelled", "x\x12tv\x07\r", []string{"två"}, "x"},
+		{"complete unique", "me\t\r", nil, "medan"},
+		{"complete common prefix", "län\t\r", nil, "längd"},
+		{"complete ambiguous", "l\tå\t\r", nil, "låt"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.input, tt.history...)
+
+		line, err := e.readLine(PROMPT)
+		if err != nil {
+			t.Errorf("%s: readLine failed: %s", tt.name, err)
+			continue
+		}
+
+		if line != tt.expected {
+			t.Errorf("%s: wrong line. want=%q, got=%q", tt.name, tt.expected, line)
+		}
+	}
+}
+
+func TestEditorInterruptAndEOF(t *testing.T) {
+	e := newTestEditor("abc\x03\x04")
+
+	if _, err := e.readLine(PROMPT); err != errInterrupted {
+		t.Errorf("Ctrl-C did not interrupt. got=%v", err)
+	}
+
+	if _, err := e.readLine(PROMPT); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line did not end input. got=%v", err)
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "historik")
+
+	e := newTestEditor("låt a = 1\r\r1 + 1\r1 + 1\r")
+	e.history = mustLoadHistory(t, path)
+	for i := 0; i < 4; i++ {
+		if _, err := e.readLine(PROMPT); err != nil {
+			t.Fatalf("readLine failed: %s", err)
+		}
+	}
+
+	expected := []string{"låt a = 1", "1 + 1"}
+	if entries := mustLoadHistory(t, path).entries; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("wrong history. want=%q, got=%q", expected, entries)
+	}
+
+	lines := strings.Repeat("1\n2\n", MaxHistory)
+	if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := mustLoadHistory(t, path).entries; len(entries) != MaxHistory {
+		t.Errorf("history not trimmed to %d lines. got=%d", MaxHistory, len(entries))
+	}
+
+	if lines := readHistoryFile(t, path); len(lines) != 2*MaxHistory {
+		t.Errorf("history file compacted early. want=%d lines, got=%d", 2*MaxHistory, len(lines))
+	}
+
+	lines += "3\n"
+	if err := os.WriteFile(path, []byte(lines), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mustLoadHistory(t, path)
+	if lines := readHistoryFile(t, path); len(lines) != MaxHistory || lines[len(lines)-1] != "3" {
+		t.Errorf("history file not compacted to %d lines. got=%d", MaxHistory, len(lines))
+	}
+}
+
+func TestHistoryCompactedWhileAdding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "historik")
+
+	h := mustLoadHistory(t, path)
+	for i := 0; i < 2*MaxHistory; i++ {
+		if err := h.add(strconv.Itoa(i)); err != nil {
+			t.Fatalf("add failed: %s", err)
+		}
+	}
+
+	if lines := readHistoryFile(t, path); len(lines) != 2*MaxHistory {
+		t.Fatalf("history file compacted early. want=%d lines, got=%d", 2*MaxHistory, len(lines))
+	}
+
+	if len(h.entries) != MaxHistory || h.entries[0] != strconv.Itoa(MaxHistory) {
+		t.Errorf("entries not trimmed to the last %d lines. got=%d from %q", MaxHistory, len(h.entries), h.entries[0])
+	}
+
+	if err := h.add("sist"); err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+
+	lines := readHistoryFile(t, path)
+	if len(lines) != MaxHistory {
+		t.Fatalf("history file not compacted to %d lines. got=%d", MaxHistory, len(lines))
+	}
+
+	if lines[0] != strconv.Itoa(MaxHistory+1) || lines[len(lines)-1] != "sist" {
+		t.Errorf("wrong lines kept. got first=%q, last=%q", lines[0], lines[len(lines)-1])
+	}
+}
+
+func TestHistoryWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "saknas", "historik")
+
+	var out bytes.Buffer
+	e := newTestEditor("1\r2\r")
+	e.out = &out
+	e.history = mustLoadHistory(t, path)
+
+	for i := 0; i < 2; i++ {
+		if _, err := e.readLine(PROMPT); err != nil {
+			t.Fatalf("readLine failed: %s", err)
+		}
+	}
+
+	if count := strings.Count(out.String(), "history not saved"); count != 1 {
+		t.Errorf("write error reported %d times, want once. output=%q", count, out.String())
+	}
+
+	if expected := []string{"1", "2"}; !reflect.DeepEqual(e.history.entries, expected) {
+		t.Errorf("wrong history. want=%q, got=%q", expected, e.history.entries)
+	}
+}
+
+func mustLoadHistory(t *testing.T, path string) *history {
+	t.Helper()
+
+	h, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory failed: %s", err)
+	}
+
+	return h
+}
+
+func TestSessionComplete(t *testing.T) {
+	s := &session{engine: &evalEngine{env: object.NewEnvironment()}}
+	s.engine.(*evalEngine).env.Set("längdmått", &object.Integer{Value: 1})
+
+	tests := []struct {
+		word     string
+		expected []string
+	}{
+		{"läng", []string{"längd", "längdmått"}},
+		{"för", []string{"för", "första", "försök"}},
+		{":re", []string{":reset"}},
+	}
+
+	for _, tt := range tests {
+		if candidates := s.complete(tt.word); !reflect.DeepEqual(candidates, tt.expected) {
+			t.Errorf("wrong completions of %q. want=%q, got=%q", tt.word, tt.expected, candidates)
+		}
+	}
+}
+
+func readHistoryFile(t *testing.T, path string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
@@ -0,0 +1,144 @@
+package repl
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxHistory is how many lines of history are kept between sessions.
+const MaxHistory = 1000
+
+// history holds the lines entered in the REPL. Lines are appended to the
+// history file as they are entered, so that they outlive the session. The
+// file is only rewritten with the last MaxHistory lines once it holds more
+// than twice as many, so that entering a line does not rewrite it.
+type history struct {
+	entries []string
+	path    string
+	lines   int // in the history file
+}
+
+// historyPath is the file the history is kept in, ~/.apa_history.
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".apa_history")
+}
+
+// loadHistory reads the history from the file at path, which need not exist.
+// An empty path keeps the history in memory only. If the file cannot be read
+// or compacted, the error is returned with a history kept in memory only.
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		h.path = ""
+		return h, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.entries = append(h.entries, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		h.path = ""
+		return h, err
+	}
+
+	h.lines = len(h.entries)
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+	}
+
+	if h.lines > 2*MaxHistory {
+		if err := h.compact(); err != nil {
+			return h, err
+		}
+	}
+
+	return h, nil
+}
+
+// add appends line to the history, unless it is blank or repeats the last
+// line. If the file cannot be written, the error is returned and the history
+// is kept in memory only from then on.
+func (h *history) add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return nil
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > MaxHistory {
+		h.entries = h.entries[len(h.entries)-MaxHistory:]
+	}
+
+	if h.path == "" {
+		return nil
+	}
+
+	if h.lines+1 > 2*MaxHistory {
+		return h.compact()
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		h.path = ""
+		return err
+	}
+
+	_, err = f.WriteString(line + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		h.path = ""
+		return err
+	}
+
+	h.lines++
+
+	return nil
+}
+
+// compact replaces the history file with the entries, the last MaxHistory
+// lines.
+func (h *history) compact() error {
+	err := os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	if err != nil {
+		h.path = ""
+		return err
+	}
+
+	h.lines = len(h.entries)
+
+	return nil
+}
+
+// search returns the index of the latest entry at or before from that
+// contains query, or -1 if there is none.
+func (h *history) search(query string, from int) int {
+	for i := min(from, len(h.entries)-1); i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,34 @@
+package repl
+
+import (
+	"errors"
+
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/parser"
+	"github.com/oliversabler/apa/token"
+)
+
+// incomplete reports whether input, which parsed with errors, stops in the
+// middle of a statement, so that the REPL should read another line rather
+// than report the errors. That is when input ends inside brackets, a string
+// or a block comment, or when the parser ran out of input, such as after an
+// operator.
+func incomplete(input string, parseErrors []*parser.ParseError) bool {
+	if len(parseErrors) != 0 {
+		return errors.Is(parseErrors[0], parser.ErrUnexpectedEOF)
+	}
+
+	l := lexer.New(input)
+	depth := 0
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+
+	return depth > 0
+}
@@ -1,49 +1,221 @@
 package repl
 
 import (
-	"bufio"
-	"fmt"
 	"io"
+	"strings"
 
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/compiler"
 	"github.com/oliversabler/apa/evaluator"
 	"github.com/oliversabler/apa/lexer"
 	"github.com/oliversabler/apa/object"
 	"github.com/oliversabler/apa/parser"
+	"github.com/oliversabler/apa/token"
+	"github.com/oliversabler/apa/vm"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT = ">> "
+	// CONTINUATION_PROMPT asks for the next line of an incomplete statement.
+	CONTINUATION_PROMPT = ".. "
+)
+
+// engine runs programs entered in the REPL, keeping its state between lines.
+type engine interface {
+	run(program *ast.Program) object.Object
+	// bindings returns the values of the globals by name.
+	bindings() map[string]object.Object
+}
+
+// evalEngine keeps its evaluator for the session, so that files imported
+// are evaluated once.
+type evalEngine struct {
+	evaluator *evaluator.Evaluator
+	env       *object.Environment
+}
+
+func (e *evalEngine) run(program *ast.Program) object.Object {
+	return e.evaluator.Eval(program, e.env)
+}
+
+func (e *evalEngine) bindings() map[string]object.Object {
+	bindings := map[string]object.Object{}
+	for _, name := range e.env.Names() {
+		bindings[name], _ = e.env.Get(name)
+	}
+
+	return bindings
+}
+
+type vmEngine struct {
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+}
+
+func newVMEngine() *vmEngine {
+	symbolTable := compiler.NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	return &vmEngine{
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+		symbolTable: symbolTable,
+	}
+}
+
+func (e *vmEngine) run(program *ast.Program) object.Object {
+	if len(program.Statements) == 0 {
+		return nil
+	}
+
+	comp := compiler.NewWithState(e.symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	bytecode := comp.Bytecode()
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	if err := machine.Run(); err != nil {
+		if runtimeError, ok := err.(*vm.RuntimeError); ok {
+			return runtimeError.Err
+		}
+		return &object.Error{Message: err.Error()}
+	}
 
+	// låt leaves nothing on the stack, the evaluator has no result for it
+	if _, ok := program.Statements[len(program.Statements)-1].(*ast.LetStatement); ok {
+		return nil
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func (e *vmEngine) bindings() map[string]object.Object {
+	bindings := map[string]object.Object{}
+	for i, name := range e.symbolTable.Names() {
+		if name != "" && e.globals[i] != nil {
+			bindings[name] = e.globals[i]
+		}
+	}
+
+	return bindings
+}
+
+// Start runs the REPL with the tree-walking evaluator.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	StartEvaluator(in, out, evaluator.New)
+}
+
+// StartEvaluator runs the REPL with the evaluators made by newEvaluator, one
+// for the session and another each time it is reset.
+func StartEvaluator(in io.Reader, out io.Writer, newEvaluator func() *evaluator.Evaluator) {
+	start(in, out, func() engine { return &evalEngine{evaluator: newEvaluator(), env: object.NewEnvironment()} })
+}
+
+// StartVM runs the REPL with the bytecode compiler and virtual machine.
+func StartVM(in io.Reader, out io.Writer) {
+	start(in, out, func() engine { return newVMEngine() })
+}
+
+// session is the state of a running REPL.
+type session struct {
+	out       io.Writer
+	engine    engine
+	newEngine func() engine
+}
+
+func start(in io.Reader, out io.Writer, newEngine func() engine) {
+	s := &session{out: out, engine: newEngine(), newEngine: newEngine}
+	reader := newLineReader(in, out, s.complete)
+	var lines []string
 
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		prompt := PROMPT
+		if len(lines) != 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		line, err := reader.readLine(prompt)
+		if err == errInterrupted {
+			lines = nil
+			continue
+		}
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
+		if len(lines) == 0 && strings.HasPrefix(line, ":") {
+			s.command(line)
+			continue
+		}
+
+		// An empty line ends an incomplete statement, reporting its errors
+		force := len(lines) != 0 && strings.TrimSpace(line) == ""
+
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+
+		l := lexer.New(input)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
+		if !force && incomplete(input, p.Errors()) {
+			continue
+		}
+
+		lines = nil
+
 		if len(p.Errors()) != 0 {
 			printParserErrors(out, p.Errors())
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		s.run(program)
+	}
+}
+
+// run runs program and prints its result.
+func (s *session) run(program *ast.Program) {
+	evaluated := s.engine.run(program)
+	if err, ok := evaluated.(*object.Error); ok {
+		io.WriteString(s.out, err.Trace())
+	} else if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// complete returns the completions of word: the commands if it starts with
+// a colon, otherwise the keywords, builtins and bindings of the session.
+func (s *session) complete(word string) []string {
+	if strings.HasPrefix(word, ":") {
+		var names []string
+		for name := range commands {
+			names = append(names, ":"+name)
 		}
+		return completions(word, names)
+	}
+
+	var builtins []string
+	for _, def := range object.Builtins {
+		builtins = append(builtins, def.Name)
+	}
+
+	var bindings []string
+	for name := range s.engine.bindings() {
+		bindings = append(bindings, name)
 	}
+
+	return completions(word, token.Keywords(), builtins, bindings)
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, message := range errors {
-		io.WriteString(out, "\t"+message+"\n")
+func printParserErrors(out io.Writer, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
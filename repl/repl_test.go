@@ -0,0 +1,78 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiLineInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"låt f = funktion(x) {\n  x * 2\n}\nf(21)\n", ">> .. .. >> 42\n>> "},
+		{"[1,\n2]\n", ">> .. [1, 2]\n>> "},
+		{"1 +\n2\n", ">> .. 3\n>> "},
+		{"försök { kasta \"oj\" }\nfånga (fel) { 1 }\n", ">> .. 1\n>> "},
+		{"`a\nb`\n", ">> .. a\nb\n>> "},
+		{"\"a\nb\"\n", ">> .. a\nb\n>> "},
+		{"1 +\n\n2\n", ">> .. \t2:1: no prefix parse function for EOF found\n>> 2\n>> "},
+		{"1 + + )\n", ">> \t1:5: no prefix parse function for + found\n>> "},
+	}
+
+	for _, tt := range tests {
+		for name, start := range map[string]func(io.Reader, io.Writer){"eval": Start, "vm": StartVM} {
+			var out bytes.Buffer
+			start(strings.NewReader(tt.input), &out)
+
+			if out.String() != tt.expected {
+				t.Errorf("%s: wrong output for %q.\nwant=%q\ngot =%q", name, tt.input, tt.expected, out.String())
+			}
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"låt b = 2\nlåt a = 1\n:env\n", ">> >> >> a = 1\nb = 2\n>> "},
+		{"låt a = 1\n:reset\n:env\n", ">> >> >> >> "},
+		{":type 1 + 1.5\n", ">> FLOAT\n>> "},
+		{":tokens låt a\n", ">> 1:1\tLET\t\"låt\"\n1:5\tIDENT\t\"a\"\n>> "},
+		{":ast -x\n", ">> Program\n  Statements[0]: ExpressionStatement\n" +
+			"    Expression: PrefixExpression Operator=\"-\"\n      Right: Identifier Value=\"x\"\n>> "},
+		{":load saknas.apa\n", ">> open saknas.apa: no such file or directory\n>> "},
+		{":hej\n", ">> unknown command :hej, the commands are " + commandHelp + "\n>> "},
+	}
+
+	for _, tt := range tests {
+		for name, start := range map[string]func(io.Reader, io.Writer){"eval": Start, "vm": StartVM} {
+			var out bytes.Buffer
+			start(strings.NewReader(tt.input), &out)
+
+			if out.String() != tt.expected {
+				t.Errorf("%s: wrong output for %q.\nwant=%q\ngot =%q", name, tt.input, tt.expected, out.String())
+			}
+		}
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "lib.apa")
+	if err := os.WriteFile(filename, []byte("låt dubbla = funktion(x) { x * 2 };"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	Start(strings.NewReader(":load "+filename+"\ndubbla(21)\n"), &out)
+
+	if expected := ">> >> 42\n>> "; out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/compiler"
+	"github.com/oliversabler/apa/evaluator"
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+	"github.com/oliversabler/apa/vm"
+)
+
+// runScript runs the program src, read from filename, with the engine given
+// by name, where the evaluator handles integer overflow as overflow says. The
+// script gets args as an array of strings in the global argument. Errors are
+// written to stderr and make the exit status 1.
+func runScript(engine string, overflow evaluator.OverflowMode, filename, src string, args []string, stderr io.Writer) int {
+	p := parser.New(lexer.NewWithFilename(filename, src))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintln(stderr, err)
+		}
+		return 1
+	}
+
+	argument := &object.Array{}
+	for _, arg := range args {
+		argument.Elements = append(argument.Elements, &object.String{Value: arg})
+	}
+
+	var err *object.Error
+	if engine == "vm" {
+		if unsupported := checkVM(program); unsupported != nil {
+			fmt.Fprintf(stderr, "%s\nimportera needs the evaluator, run the script with -engine eval\n", unsupported)
+			return 2
+		}
+		err = runVM(program, argument)
+	} else {
+		env := object.NewEnvironment()
+		env.Set("argument", argument)
+		e := evaluator.New()
+		e.Overflow = overflow
+		err, _ = e.EvalFile(filename, program, env).(*object.Error)
+	}
+
+	if err != nil {
+		io.WriteString(stderr, err.Trace())
+		return 1
+	}
+
+	return 0
+}
+
+// checkVM returns the error of compiling a program the VM cannot run, because
+// it imports modules, before any of it has run.
+func checkVM(program *ast.Program) error {
+	if err := compiler.New().Compile(program); errors.Is(err, compiler.ErrModulesUnsupported) {
+		return err
+	}
+
+	return nil
+}
+
+func runVM(program *ast.Program, argument *object.Array) *object.Error {
+	symbolTable := compiler.NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[symbolTable.Define("argument").Index] = argument
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	if err := machine.Run(); err != nil {
+		if runtimeError, ok := err.(*vm.RuntimeError); ok {
+			return runtimeError.Err
+		}
+		return &object.Error{Message: err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oliversabler/apa/evaluator"
+)
+
+func TestRunScript(t *testing.T) {
+	tests := []struct {
+		src      string
+		status   int
+		expected string
+	}{
+		{`om (längd(argument) != 2) { kasta "fel argument" }`, 0, ""},
+		{"låt = 1", 1, "skript.apa:1:5: expected next token to be IDENT, got==\n"},
+		{"1 +\nx", 1, "ERROR: identifier not found: x\n\tat skript.apa:2:1\n"},
+		{
+			"låt f = funktion(n) {\n  om (n == 0) { kasta \"botten\" }\n  f(n - 1) + 1\n};\nf(3)",
+			1,
+			"ERROR: botten\n\tat skript.apa:2:17\n\tin f called at skript.apa:3:3\n\t... repeated 2 more times\n\tin f called at skript.apa:5:1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, engine := range []string{"eval", "vm"} {
+			var stderr bytes.Buffer
+
+			status := runScript(engine, evaluator.OverflowPromote, "skript.apa", tt.src, []string{"a", "b"}, &stderr)
+			if status != tt.status {
+				t.Errorf("%s: wrong exit status for %q. want=%d, got=%d", engine, tt.src, tt.status, status)
+			}
+
+			if stderr.String() != tt.expected {
+				t.Errorf("%s: wrong stderr for %q. want=%q, got=%q", engine, tt.src, tt.expected, stderr.String())
+			}
+		}
+	}
+}
+
+func TestRunScriptRejectsImportsOnVM(t *testing.T) {
+	src := "låt x = 1;\nimportera \"matte.apa\";\nskriv(x)"
+
+	var stderr bytes.Buffer
+
+	status := runScript("vm", evaluator.OverflowPromote, "skript.apa", src, nil, &stderr)
+	if status != 2 {
+		t.Errorf("wrong exit status. want=2, got=%d", status)
+	}
+
+	expected := `skript.apa:2:1: modules are not supported by the vm engine: importera "matte.apa";
+importera needs the evaluator, run the script with -engine eval
+`
+
+	if stderr.String() != expected {
+		t.Errorf("wrong stderr. want=%q, got=%q", expected, stderr.String())
+	}
+}
+
+func TestRunScriptImportCycle(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "a.apa")
+	src := `importera "b.apa"; skriv("a")`
+
+	if err := os.WriteFile(filepath.Join(dir, "b.apa"), []byte(`importera "a.apa";`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stderr bytes.Buffer
+
+	if status := runScript("eval", evaluator.OverflowPromote, main, src, nil, &stderr); status != 1 {
+		t.Errorf("wrong exit status. want=1, got=%d", status)
+	}
+
+	if !strings.HasPrefix(stderr.String(), "ERROR: import cycle: a.apa -> b.apa -> a.apa\n") {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestRunScriptOverflow(t *testing.T) {
+	tests := []struct {
+		overflow evaluator.OverflowMode
+		status   int
+		expected string
+	}{
+		{evaluator.OverflowPromote, 0, ""},
+		{evaluator.OverflowWrap, 0, ""},
+		{evaluator.OverflowError, 1, "ERROR: integer overflow: 9223372036854775807 + 1\n\tat skript.apa:1:1\n"},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		status := runScript("eval", tt.overflow, "skript.apa", "9223372036854775807 + 1", nil, &stderr)
+
+		if status != tt.status {
+			t.Errorf("wrong status. want=%d, got=%d", tt.status, status)
+		}
+
+		if stderr.String() != tt.expected {
+			t.Errorf("wrong stderr. want=%q, got=%q", tt.expected, stderr.String())
+		}
+	}
+}
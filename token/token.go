@@ -1,18 +1,71 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+}
+
+// Position is a location in the source. Line and Column are 1-based, with
+// Column counted in runes; Offset is the 0-based byte offset into the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	location := p.Filename
+
+	if p.IsValid() {
+		if location != "" {
+			location += ":"
+		}
+		location += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	if location == "" {
+		return "-"
+	}
+
+	return location
+}
+
+// Span covers the source from Start up to, but not including, End.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String()
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
+	ERROR   = "ERROR"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
+
+	// UNTERMINATED is the ERROR of a string or comment that is still open
+	// at the end of the input
+	UNTERMINATED = "UNTERMINATED"
 
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	ASSIGN   = "="
@@ -21,16 +74,29 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
 
 	EQUAL    = "=="
 	NOTEQUAL = "!="
 
-	LT = "<"
-	GT = ">"
+	LT  = "<"
+	GT  = ">"
+	LTE = "<="
+	GTE = ">="
+
+	AND = "&&"
+	OR  = "||"
 
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -46,16 +112,46 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
 )
 
 var keywords = map[string]TokenType{
-	"funktion": FUNCTION,
-	"låt":      LET,
-	"sant":     TRUE,
-	"falskt":   FALSE,
-	"om":       IF,
-	"annars":   ELSE,
-	"tillbaka": RETURN,
+	"funktion":  FUNCTION,
+	"låt":       LET,
+	"sant":      TRUE,
+	"falskt":    FALSE,
+	"om":        IF,
+	"annars":    ELSE,
+	"tillbaka":  RETURN,
+	"medan":     WHILE,
+	"för":       FOR,
+	"bryt":      BREAK,
+	"fortsätt":  CONTINUE,
+	"försök":    TRY,
+	"fånga":     CATCH,
+	"kasta":     THROW,
+	"importera": IMPORT,
+	"och":       AND,
+	"eller":     OR,
+}
+
+// Keywords returns the keywords of the language in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+
+	sort.Strings(words)
+
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/oliversabler/apa/object"
+)
+
+// RuntimeError is an error raised while running, it can be caught by fånga
+// unless the run was stopped.
+type RuntimeError struct {
+	Err *object.Error
+	// Cause is why the run was stopped: ctx.Err(), object.ErrStepBudget or
+	// object.ErrAllocationBudget. It is nil for errors raised by the program.
+	Cause error
+}
+
+func (re *RuntimeError) Error() string {
+	return re.Err.Message
+}
+
+func (re *RuntimeError) Unwrap() error {
+	return re.Cause
+}
+
+func newRuntimeError(kind string, format string, a ...interface{}) error {
+	return &RuntimeError{Err: &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}}
+}
@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"github.com/oliversabler/apa/code"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/token"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+	// call is where the function was called from if it was a tail call,
+	// which replaced the frame of the function making it
+	call token.Position
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	f := &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+
+	return f
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
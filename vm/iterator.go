@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/oliversabler/apa/object"
+)
+
+const ITERATOR_OBJ = "ITERATOR"
+
+// iterator walks the keys and values of an array, hash or string. It only
+// ever lives on the stack while a för loop runs.
+type iterator struct {
+	keys   []object.Object
+	values []object.Object
+	pos    int
+}
+
+func (i *iterator) Inspect() string {
+	return fmt.Sprintf("Iterator[%p]", i)
+}
+
+func (i *iterator) Type() object.ObjectType {
+	return ITERATOR_OBJ
+}
+
+func (i *iterator) next() (object.Object, object.Object, bool) {
+	if i.pos >= len(i.values) {
+		return nil, nil, false
+	}
+
+	key, value := i.keys[i.pos], i.values[i.pos]
+	i.pos++
+
+	return key, value, true
+}
+
+// newIterator iterates arrays and strings by index, and hashes in key order.
+// A hash iterated without a key binding yields its keys as values.
+func newIterator(iterable object.Object, hasKey bool) (*iterator, error) {
+	it := &iterator{}
+
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		it.values = iterable.Elements
+		for i := range it.values {
+			it.keys = append(it.keys, &object.Integer{Value: int64(i)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+			it.keys = append(it.keys, pair.Key)
+			it.values = append(it.values, pair.Value)
+		}
+		if !hasKey {
+			it.values = it.keys
+		}
+	case *object.String:
+		i := 0
+		for _, ch := range iterable.Value {
+			it.keys = append(it.keys, &object.Integer{Value: int64(i)})
+			it.values = append(it.values, &object.String{Value: string(ch)})
+			i++
+		}
+	default:
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "för not supported over %s", iterable.Type())
+	}
+
+	return it, nil
+}
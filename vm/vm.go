@@ -0,0 +1,1101 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/oliversabler/apa/code"
+	"github.com/oliversabler/apa/compiler"
+	"github.com/oliversabler/apa/object"
+)
+
+// StackSize is the size the stack starts with, it grows as needed.
+const StackSize = 2048
+const GlobalsSize = 65536
+
+// MaxDepth is how deep function calls may nest, the same as
+// evaluator.DefaultMaxDepth so that both engines stop at the same depth.
+const MaxDepth = 10000
+
+var True = &object.Boolean{Value: true}
+var False = &object.Boolean{Value: false}
+var Null = &object.Null{}
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	globals     []object.Object
+	globalNames []string
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+	loops    []loop
+
+	// MaxSteps limits the number of function calls and loop iterations.
+	// Zero or less means no limit.
+	MaxSteps int
+
+	// MaxAllocations limits the size of the values created, counted as
+	// object.Allocation does. Zero or less means no limit.
+	MaxAllocations int
+
+	ctx         context.Context
+	steps       int
+	allocations int
+}
+
+// handler is where to continue when an error is raised inside a försök,
+// with the frame and stack as they were when it was entered.
+type handler struct {
+	framesIndex int
+	sp          int
+	pos         int
+	loops       int
+}
+
+// loop is the frame and stack as they were when a loop was entered, which
+// bryt and fortsätt unwind the stack to.
+type loop struct {
+	framesIndex int
+	sp          int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := []*Frame{mainFrame}
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+// Run runs the program until it is done or raises an error that is not
+// caught by a fånga.
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext is Run, stopping when ctx is done or a budget runs out, which
+// counts steps and allocations the same way as the evaluator. The error is
+// then a *RuntimeError that wraps ctx.Err(), object.ErrStepBudget or
+// object.ErrAllocationBudget.
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.ctx = ctx
+	vm.steps = 0
+	vm.allocations = 0
+
+	for {
+		err := vm.run()
+		if runtimeError, ok := err.(*RuntimeError); ok {
+			vm.locate(runtimeError.Err)
+		}
+
+		if err == nil || !vm.catch(err) {
+			return err
+		}
+	}
+}
+
+// locate records where err was raised and the calls it propagated out of,
+// innermost first, from the frames as they are when it is raised.
+func (vm *VM) locate(err *object.Error) {
+	if err.Pos.IsValid() || len(err.Stack) != 0 {
+		return
+	}
+
+	frame := vm.currentFrame()
+	err.Pos = frame.cl.Fn.PositionAt(frame.ip)
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		callee := vm.frames[i]
+
+		call := callee.call
+		if !call.IsValid() {
+			caller := vm.frames[i-1]
+			call = caller.cl.Fn.PositionAt(caller.ip)
+		}
+
+		err.Stack = append(err.Stack, object.StackFrame{Function: callee.cl.Fn.Name, Call: call})
+	}
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual,
+			code.OpGreaterThan, code.OpGreaterEqual, code.OpLessThan, code.OpLessEqual:
+			right := vm.pop()
+			left := vm.pop()
+
+			result, err := executeBinaryOperation(op, left, right)
+			if err != nil {
+				return err
+			}
+
+			if err := vm.push(vm.allocate(result)); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpBang:
+			if err := vm.executeBangOperator(); err != nil {
+				return err
+			}
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.pop()
+			if cell, ok := vm.globals[globalIndex].(*object.Cell); ok {
+				cell.Value = value
+			} else {
+				vm.globals[globalIndex] = value
+			}
+
+		case code.OpBindGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+
+			if value == nil {
+				return identifierNotFound(vm.globalNames, int(globalIndex))
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			value := vm.pop()
+
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = value
+			} else {
+				*slot = value
+			}
+
+		case code.OpBindLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			value := vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if cell, ok := value.(*object.Cell); ok {
+				value = cell.Value
+			}
+
+			if value == nil {
+				return identifierNotFound(vm.currentFrame().cl.Fn.LocalNames, int(localIndex))
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			definition := object.Builtins[builtinIndex]
+
+			if err := vm.push(definition.Builtin); err != nil {
+				return err
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			value := currentClosure.Free[freeIndex].(*object.Cell).Value
+			if value == nil {
+				return identifierNotFound(currentClosure.Fn.FreeNames, int(freeIndex))
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].(*object.Cell).Value = vm.pop()
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(vm.captureLocal(int(localIndex))); err != nil {
+				return err
+			}
+
+		case code.OpCaptureGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(captureSlot(&vm.globals[globalIndex])); err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(vm.currentFrame().cl.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(vm.allocate(array)); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(vm.allocate(hash)); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			operator := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSetIndex(operator, left, index, value); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.step(); err != nil {
+				return err
+			}
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.step(); err != nil {
+				return err
+			}
+
+			if err := vm.executeTailCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			// tillbaka outside of a function ends the program with its value
+			// as the last popped element
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			hasKey := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			it, err := newIterator(vm.pop(), hasKey == 1)
+			if err != nil {
+				return err
+			}
+
+			if err := vm.push(it); err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			it := vm.stack[vm.sp-1].(*iterator)
+
+			key, value, ok := it.next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+
+			if err := vm.step(); err != nil {
+				return err
+			}
+
+			if err := vm.push(key); err != nil {
+				return err
+			}
+
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpStep:
+			if err := vm.step(); err != nil {
+				return err
+			}
+
+		case code.OpLoop:
+			vm.loops = append(vm.loops, loop{framesIndex: vm.framesIndex, sp: vm.sp})
+
+		case code.OpEndLoop:
+			vm.loops = vm.loops[:len(vm.loops)-1]
+
+		case code.OpLoopJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+
+			vm.sp = vm.loops[len(vm.loops)-1].sp
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			h := handler{framesIndex: vm.framesIndex, sp: vm.sp, pos: pos, loops: len(vm.loops)}
+			vm.handlers = append(vm.handlers, h)
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			return throw(vm.pop())
+		}
+	}
+
+	return nil
+}
+
+// catch unwinds to the innermost försök, pushing the error as a value for its
+// fånga. It reports whether there was a försök to catch the error.
+func (vm *VM) catch(err error) bool {
+	runtimeError, ok := err.(*RuntimeError)
+	if !ok || runtimeError.Cause != nil || len(vm.handlers) == 0 {
+		return false
+	}
+
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	vm.loops = vm.loops[:h.loops]
+	vm.currentFrame().ip = h.pos - 1
+
+	// The handler restores the stack to where it was, so there is room
+	vm.push(&object.ErrorValue{Error: runtimeError.Err})
+
+	return true
+}
+
+func throw(value object.Object) error {
+	switch value := value.(type) {
+	case *object.ErrorValue:
+		// A caught error is thrown again as a new error raised here, the
+		// one the handler holds keeps where it was first raised
+		return &RuntimeError{Err: &object.Error{Message: value.Error.Message, Kind: value.Error.Kind}}
+	case *object.String:
+		return &RuntimeError{Err: &object.Error{Message: value.Value}}
+	default:
+		return &RuntimeError{Err: &object.Error{Message: value.Inspect()}}
+	}
+}
+
+/*
+   BUDGETS
+*/
+
+// step counts a function call or loop iteration and checks whether the run
+// has to stop, because its context is done or a budget has run out.
+func (vm *VM) step() error {
+	vm.steps++
+
+	var cause error
+	switch {
+	case vm.ctx != nil && vm.ctx.Err() != nil:
+		cause = vm.ctx.Err()
+	case vm.MaxSteps > 0 && vm.steps > vm.MaxSteps:
+		cause = object.ErrStepBudget
+	case vm.MaxAllocations > 0 && vm.allocations > vm.MaxAllocations:
+		cause = object.ErrAllocationBudget
+	default:
+		return nil
+	}
+
+	message := fmt.Sprintf("evaluation stopped: %s", cause)
+	return &RuntimeError{Err: &object.Error{Message: message}, Cause: cause}
+}
+
+// allocate counts obj towards the allocation budget and returns it.
+func (vm *VM) allocate(obj object.Object) object.Object {
+	vm.allocations += object.Allocation(obj)
+
+	return obj
+}
+
+/*
+   STACK
+*/
+
+func (vm *VM) push(o object.Object) error {
+	vm.grow(vm.sp + 1)
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// grow makes room for size values on the stack.
+func (vm *VM) grow(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+
+	stack := make([]object.Object, max(size, 2*len(vm.stack)))
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+/*
+   FRAMES
+*/
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	// The main frame is not a call
+	if vm.framesIndex > MaxDepth {
+		return newRuntimeError(object.RECURSION_ERROR_KIND, "maximalt rekursionsdjup överskridet")
+	}
+
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+
+	// Leaving a frame leaves the försök and loops entered in it
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+
+	for len(vm.loops) > 0 && vm.loops[len(vm.loops)-1].framesIndex > vm.framesIndex {
+		vm.loops = vm.loops[:len(vm.loops)-1]
+	}
+
+	return vm.frames[vm.framesIndex]
+}
+
+/*
+   CALLS
+*/
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newRuntimeError(object.TYPE_ERROR_KIND, "not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newRuntimeError(object.ARGUMENT_ERROR_KIND, "wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	vm.grow(basePointer + cl.Fn.NumLocals)
+
+	frame := NewFrame(cl, basePointer)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	// Clear the slots of the locals, they may still hold values or cells
+	// left behind by an earlier call
+	for i := basePointer + numArgs; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.sp = basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+// executeTailCall calls a function in place of the function making the call,
+// whose frame it takes over, so that a chain of tail calls does not nest.
+func (vm *VM) executeTailCall(numArgs int) error {
+	callee, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || numArgs != callee.Fn.NumParameters {
+		// The OpReturnValue after the call returns the result of a builtin,
+		// or the call reports what is wrong with it
+		return vm.executeCall(numArgs)
+	}
+
+	frame := vm.currentFrame()
+	call := frame.cl.Fn.PositionAt(frame.ip)
+
+	// The function and arguments take the place of those of the frame
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = frame.basePointer + numArgs
+	vm.popFrame()
+
+	if err := vm.callClosure(callee, numArgs); err != nil {
+		return err
+	}
+	vm.currentFrame().call = call
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := vm.allocate(builtin.Fn(args...))
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		return &RuntimeError{Err: err}
+	}
+
+	if result == nil {
+		result = Null
+	}
+
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return newRuntimeError(object.TYPE_ERROR_KIND, "not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+// captureLocal moves a local into a cell shared by the frame and the closures
+// capturing it, so that assignments on either side are seen by the other.
+func (vm *VM) captureLocal(localIndex int) *object.Cell {
+	return captureSlot(&vm.stack[vm.currentFrame().basePointer+localIndex])
+}
+
+// captureSlot moves the variable in slot into a cell, unless it is in one.
+func captureSlot(slot *object.Object) *object.Cell {
+	if cell, ok := (*slot).(*object.Cell); ok {
+		return cell
+	}
+
+	cell := &object.Cell{Value: *slot}
+	*slot = cell
+
+	return cell
+}
+
+/*
+   OPERATIONS
+*/
+
+func executeBinaryOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case object.IsInteger(left) && object.IsInteger(right):
+		return executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return executeBinaryFloatOperation(op, left, right)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		return executeBinaryStringOperation(op, left, right)
+	case op == code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case op == code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case leftType != rightType:
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "type mismatch: %s %s %s", leftType, operators[op], rightType)
+	default:
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", leftType, operators[op], rightType)
+	}
+}
+
+func executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	switch op {
+	case code.OpAdd, code.OpSub, code.OpMul:
+		return object.IntegerArithmetic(operators[op], left, right), nil
+	case code.OpDiv, code.OpMod:
+		if object.IsZero(right) {
+			return nil, newRuntimeError(object.ARITHMETIC_ERROR_KIND, "division by zero")
+		}
+		return object.IntegerArithmetic(operators[op], left, right), nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) == 0), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) != 0), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) > 0), nil
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) >= 0), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) < 0), nil
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) <= 0), nil
+	default:
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func executeBinaryFloatOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+
+	switch op {
+	case code.OpAdd:
+		return &object.Float{Value: leftValue + rightValue}, nil
+	case code.OpSub:
+		return &object.Float{Value: leftValue - rightValue}, nil
+	case code.OpMul:
+		return &object.Float{Value: leftValue * rightValue}, nil
+	case code.OpDiv:
+		return &object.Float{Value: leftValue / rightValue}, nil
+	case code.OpMod:
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(leftValue == rightValue), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(leftValue != rightValue), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(leftValue > rightValue), nil
+	case code.OpGreaterEqual:
+		return nativeBoolToBooleanObject(leftValue >= rightValue), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(leftValue < rightValue), nil
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(leftValue <= rightValue), nil
+	default:
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func executeBinaryStringOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	if op != code.OpAdd {
+		return nil, newRuntimeError(object.TYPE_ERROR_KIND, "unknown operator: %s %s %s", left.Type(), operators[op], right.Type())
+	}
+
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	return &object.String{Value: leftValue + rightValue}, nil
+}
+
+func (vm *VM) executeBangOperator() error {
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		return vm.push(False)
+	case False:
+		return vm.push(True)
+	case Null:
+		return vm.push(True)
+	default:
+		return vm.push(False)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	switch operand := operand.(type) {
+	case *object.Integer, *object.BigInt:
+		return vm.push(object.Negate(operand))
+	case *object.Float:
+		return vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return newRuntimeError(object.TYPE_ERROR_KIND, "unknown operator: -%s", operand.Type())
+	}
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return vm.executeErrorField(left, index)
+	default:
+		return newRuntimeError(object.TYPE_ERROR_KIND, "index operator not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) error {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	max := int64(len(arrayObject.Elements) - 1)
+
+	if i < 0 || i > max {
+		return vm.push(Null)
+	}
+
+	return vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newRuntimeError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", index.Type())
+	}
+
+	pair, ok := hashObject.Pairs[key.HashKey()]
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(pair.Value)
+}
+
+func (vm *VM) executeErrorField(errorValue, index object.Object) error {
+	field, ok := errorValue.(*object.ErrorValue).Field(index.(*object.String).Value)
+	if !ok {
+		return vm.push(Null)
+	}
+
+	return vm.push(field)
+}
+
+// executeSetIndex stores value at left[index] and pushes the stored value.
+// A non-zero operator combines the current element with value first, as in
+// a compound assignment such as a[i] += 1.
+func (vm *VM) executeSetIndex(operator code.Opcode, left, index, value object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newRuntimeError(object.TYPE_ERROR_KIND, "index operator not supported: %s[%s]", left.Type(), index.Type())
+		}
+
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newRuntimeError(object.INDEX_ERROR_KIND, "index out of range: %d", i.Value)
+		}
+
+		value, err := vm.assignedValue(operator, left.Elements[i.Value], value)
+		if err != nil {
+			return err
+		}
+
+		left.Elements[i.Value] = value
+
+		return vm.push(value)
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newRuntimeError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", index.Type())
+		}
+
+		current := object.Object(Null)
+		if pair, ok := left.Pairs[key.HashKey()]; ok {
+			current = pair.Value
+		}
+
+		value, err := vm.assignedValue(operator, current, value)
+		if err != nil {
+			return err
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+
+		return vm.push(value)
+	default:
+		return newRuntimeError(object.TYPE_ERROR_KIND, "index assignment not supported: %s", left.Type())
+	}
+}
+
+func (vm *VM) assignedValue(operator code.Opcode, current, value object.Object) (object.Object, error) {
+	if operator == 0 {
+		return value, nil
+	}
+
+	result, err := executeBinaryOperation(operator, current, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.allocate(result), nil
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		pair := object.HashPair{Key: key, Value: value}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, newRuntimeError(object.TYPE_ERROR_KIND, "unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = pair
+	}
+
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+/*
+   HELPERS
+*/
+
+func identifierNotFound(names []string, index int) error {
+	return newRuntimeError(object.NAME_ERROR_KIND, "identifier not found: %s", names[index])
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return object.IsInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat widens a number to float64, callers must check isNumber first.
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return object.BigToFloat(obj)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+
+	return False
+}
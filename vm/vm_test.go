@@ -0,0 +1,249 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/oliversabler/apa/ast"
+	"github.com/oliversabler/apa/compiler"
+	"github.com/oliversabler/apa/corpus"
+	"github.com/oliversabler/apa/evaluator"
+	"github.com/oliversabler/apa/lexer"
+	"github.com/oliversabler/apa/object"
+	"github.com/oliversabler/apa/parser"
+)
+
+func TestCorpusMatchesEvaluator(t *testing.T) {
+	for _, tt := range corpus.Cases {
+		program := parse(tt.Input)
+
+		evaluated := evaluator.Eval(program, object.NewEnvironment())
+		if evaluated == nil {
+			t.Errorf("%q evaluated to nil", tt.Input)
+			continue
+		}
+
+		got := runVM(t, program)
+
+		if got != evaluated.Inspect() {
+			t.Errorf("vm and evaluator differ for %q. evaluator=%q, vm=%q",
+				tt.Input, evaluated.Inspect(), got)
+		}
+
+		if got != tt.Expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.Input, tt.Expected, got)
+		}
+	}
+}
+
+func TestCorpusTraces(t *testing.T) {
+	for _, tt := range corpus.Traces {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.Input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		runtimeError, ok := New(comp.Bytecode()).Run().(*RuntimeError)
+		if !ok {
+			t.Errorf("%q did not fail", tt.Input)
+			continue
+		}
+
+		if runtimeError.Err.Trace() != tt.Trace {
+			t.Errorf("wrong trace for %q. want=%q, got=%q", tt.Input, tt.Trace, runtimeError.Err.Trace())
+		}
+	}
+}
+
+func TestCorpusBudgets(t *testing.T) {
+	for _, tt := range corpus.Budgets {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.Input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.Cancelled {
+			cancel()
+		}
+
+		machine := New(comp.Bytecode())
+		machine.MaxSteps = tt.MaxSteps
+		machine.MaxAllocations = tt.MaxAllocations
+
+		var got string
+		if err := machine.RunContext(ctx); err != nil {
+			got = (&object.Error{Message: err.Error()}).Inspect()
+		} else {
+			got = machine.LastPoppedStackElem().Inspect()
+		}
+		cancel()
+
+		if got != tt.Expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.Input, tt.Expected, got)
+		}
+	}
+}
+
+func TestRunContextCause(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse("medan (sant) { }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	machine.MaxSteps = 100
+
+	err := machine.RunContext(context.Background())
+	if !errors.Is(err, object.ErrStepBudget) {
+		t.Fatalf("wrong error. want=%v, got=%v", object.ErrStepBudget, err)
+	}
+}
+
+func TestClosuresShareCapturedVariables(t *testing.T) {
+	input := `
+låt par = funktion() {
+  låt n = 0;
+  [funktion() { n += 1 }, funktion() { n }]
+};
+låt p = par();
+p[0]();
+p[0]();
+p[1]();`
+
+	if got := runVM(t, parse(input)); got != "2" {
+		t.Errorf("wrong result. want=%q, got=%q", "2", got)
+	}
+}
+
+func TestRecursiveClosureInLoop(t *testing.T) {
+	input := `
+låt summa = 0;
+för (x i [1, 2, 3]) {
+  låt räkna = funktion(n) { om (n == 0) { 0 } annars { 1 + räkna(n - 1) } };
+  summa += räkna(x);
+}
+summa;`
+
+	if got := runVM(t, parse(input)); got != "6" {
+		t.Errorf("wrong result. want=%q, got=%q", "6", got)
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	input := "funktion(a, b) { a }(1)"
+
+	if got := runVM(t, parse(input)); got != "ERROR: wrong number of arguments: want=2, got=1" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	if MaxDepth != evaluator.DefaultMaxDepth {
+		t.Errorf("MaxDepth differs from the evaluator. want=%d, got=%d", evaluator.DefaultMaxDepth, MaxDepth)
+	}
+
+	input := "låt djup = funktion(n) { om (n == 0) { 0 } annars { 1 + djup(n - 1) } }; djup(%d)"
+
+	if got := runVM(t, parse(fmt.Sprintf(input, MaxDepth-1))); got != fmt.Sprint(MaxDepth-1) {
+		t.Errorf("wrong result. got=%q", got)
+	}
+
+	if got := runVM(t, parse(fmt.Sprintf(input, MaxDepth))); got != "ERROR: maximalt rekursionsdjup överskridet" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+
+	if got := runVM(t, parse("låt f = funktion() { f() + 1 }; f()")); got != "ERROR: maximalt rekursionsdjup överskridet" {
+		t.Errorf("wrong result. got=%q", got)
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `låt inre = funktion(x) {
+  x + sant
+};
+låt yttre = funktion() {
+  inre(1) + 1
+};
+[1, funktion() { yttre() }()]`
+
+	program := parse(input)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	runtimeError, ok := machine.Run().(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected a *RuntimeError")
+	}
+
+	evaluated, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("expected the evaluator to fail")
+	}
+
+	if runtimeError.Err.Trace() != evaluated.Trace() {
+		t.Errorf("vm and evaluator traces differ. evaluator=%q, vm=%q", evaluated.Trace(), runtimeError.Err.Trace())
+	}
+}
+
+func TestGlobalsPersistBetweenRuns(t *testing.T) {
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	for i, def := range object.Builtins {
+		symbolTable.DefineBuiltin(i, def.Name)
+	}
+
+	inputs := []string{"låt a = 5;", "låt f = funktion() { a * 2 };", "f()"}
+
+	var last object.Object
+	for _, input := range inputs {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsStore(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+		last = machine.LastPoppedStackElem()
+	}
+
+	if last.Inspect() != "10" {
+		t.Errorf("wrong result. want=%q, got=%q", "10", last.Inspect())
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// runVM compiles and runs program, returning the Inspect of the result or
+// of the error as the evaluator would report it.
+func runVM(t *testing.T, program *ast.Program) string {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return (&object.Error{Message: err.Error()}).Inspect()
+	}
+
+	return machine.LastPoppedStackElem().Inspect()
+}